@@ -0,0 +1,210 @@
+package telemetry
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// BatchListener handles a batch of events at once, which lets high-throughput sinks avoid per-event I/O.
+type BatchListener func([]*Event)
+
+// BatchSink is a Sink that can also handle a batch of events at once. Writer is a BatchSink.
+type BatchSink interface {
+	Sink
+
+	// ListenBatch handles a batch of events.
+	ListenBatch(events []*Event)
+}
+
+// Creates new Batcher that collects events and hands them to `listener` in batches.
+func NewBatcher(listener BatchListener) *Batcher {
+	return &Batcher{
+		MaxEvents:  100,
+		MaxBytes:   1 << 20,
+		MaxLatency: time.Second,
+		Size:       EstimateSize,
+		listener:   listener,
+	}
+}
+
+// Creates new Batcher that collects events and hands them to `sink` in batches. Flushing or closing the Batcher
+// flushes or closes the sink as well.
+func NewSinkBatcher(sink BatchSink) *Batcher {
+	batcher := NewBatcher(sink.ListenBatch)
+	batcher.sink = sink
+	return batcher
+}
+
+// Batcher is a Sink that collects events into batches for a BatchListener, so that a BatchListener can be added to
+// an Emitter. A batch is handed over once it holds MaxEvents events or MaxBytes bytes, or MaxLatency after its
+// first event was collected, whichever comes first. Set the properties before adding the Batcher to an Emitter.
+type Batcher struct {
+	// Maximum number of events in a batch. Default is 100.
+	MaxEvents int
+
+	// Maximum size of a batch in bytes, as estimated by Size. Zero means no limit. Default is 1 MiB.
+	MaxBytes int
+
+	// Maximum time an event waits for its batch to be handed over. Zero means no limit. Default is one second.
+	MaxLatency time.Duration
+
+	// Estimates size of an event in bytes. Default is EstimateSize.
+	Size func(*Event) int
+
+	listener BatchListener
+
+	// Sink to flush and close along with the Batcher, if any.
+	sink BatchSink
+
+	// Events collected so far and their estimated size.
+	batch []*Event
+	bytes int
+
+	// Fires MaxLatency after the first event of the current batch was collected. Generation identifies the batch
+	// the timer was started for.
+	timer      *time.Timer
+	generation uint64
+
+	closed bool
+
+	// Use for locking when collecting events.
+	mutex sync.Mutex
+
+	// Held while handing over a batch, so that batches are handed over in order.
+	deliveryMutex sync.Mutex
+}
+
+// Listen collects the event into the current batch, handing the batch over if it is full.
+func (b *Batcher) Listen(event *Event) {
+	b.mutex.Lock()
+	if b.closed {
+		b.mutex.Unlock()
+		return
+	}
+	b.batch = append(b.batch, event)
+	if b.MaxBytes > 0 {
+		b.bytes += b.Size(event)
+	}
+	if len(b.batch) >= b.MaxEvents || (b.MaxBytes > 0 && b.bytes >= b.MaxBytes) {
+		b.deliver() // unlocks mutex
+		return
+	}
+	if len(b.batch) == 1 && b.MaxLatency > 0 {
+		generation := b.generation
+		b.timer = time.AfterFunc(b.MaxLatency, func() {
+			b.expire(generation)
+		})
+	}
+	b.mutex.Unlock()
+}
+
+// Hands over the current batch and then flushes the sink, if any. Gives up when the context is done.
+func (b *Batcher) Flush(ctx context.Context) error {
+	err := run(ctx, func() error {
+		b.mutex.Lock()
+		b.deliver()
+		return nil
+	})
+	if err != nil || b.sink == nil {
+		return err
+	}
+	return b.sink.Flush(ctx)
+}
+
+// Hands over the current batch and then closes the sink, if any. Events collected after Close are discarded.
+// Gives up when the context is done.
+func (b *Batcher) Close(ctx context.Context) error {
+	err := run(ctx, func() error {
+		b.mutex.Lock()
+		b.closed = true
+		b.deliver()
+		return nil
+	})
+	if err != nil || b.sink == nil {
+		return err
+	}
+	return b.sink.Close(ctx)
+}
+
+// Hands over the batch started in the given generation if it has not been handed over already.
+func (b *Batcher) expire(generation uint64) {
+	b.mutex.Lock()
+	if b.generation != generation {
+		b.mutex.Unlock()
+		return
+	}
+	b.deliver()
+}
+
+// Hands over the current batch, if not empty, and starts a new one. Must be called with mutex held, which it
+// unlocks.
+func (b *Batcher) deliver() {
+	batch := b.batch
+	b.batch = nil
+	b.bytes = 0
+	b.generation++
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	if len(batch) == 0 {
+		b.mutex.Unlock()
+		return
+	}
+	b.deliveryMutex.Lock()
+	b.mutex.Unlock()
+	defer b.deliveryMutex.Unlock()
+	b.listener(batch)
+}
+
+// Estimates size of the event in bytes when serialized, without serializing it.
+func EstimateSize(event *Event) int {
+	size := 2 + estimateSize(event.data.flatten())
+	if event.provenance != nil {
+		size += len(ProvenanceKey) + 4
+		for _, provenance := range event.provenance {
+			size += estimateSize(provenance) + 1
+		}
+	}
+	return size
+}
+
+func estimateSize(value interface{}) int {
+	switch value := value.(type) {
+	case nil:
+		return 4
+	case string:
+		return len(value) + 2
+	case Fields:
+		return estimateMapSize(value)
+	case map[string]interface{}:
+		return estimateMapSize(value)
+	case []interface{}:
+		size := 2
+		for _, v := range value {
+			size += estimateSize(v) + 1
+		}
+		return size
+	case []string:
+		size := 2
+		for _, v := range value {
+			size += len(v) + 3
+		}
+		return size
+	case error:
+		return len(value.Error()) + 2
+	case Valuer:
+		return estimateSize(resolve(value))
+	default:
+		return 8
+	}
+}
+
+func estimateMapSize(fields map[string]interface{}) int {
+	size := 2
+	for k, v := range fields {
+		size += len(k) + 4 + estimateSize(v)
+	}
+	return size
+}
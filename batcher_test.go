@@ -0,0 +1,90 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatcherHandsOverFullBatches(t *testing.T) {
+	var batches [][]*Event
+	batcher := NewBatcher(func(events []*Event) {
+		batches = append(batches, events)
+	})
+	batcher.MaxEvents = 3
+	batcher.MaxLatency = 0
+	emitter := NewEmitter()
+	emitter.AddSink(batcher)
+	for i := 0; i < 7; i++ {
+		emitter.Emit(New().WithField("i", i))
+	}
+	require.Len(t, batches, 2)
+	require.NoError(t, emitter.Flush(context.Background()))
+	require.Len(t, batches, 3)
+	require.Len(t, batches[2], 1)
+	require.Equal(t, 6, field(batches[2][0], "i"))
+}
+
+func TestBatcherHandsOverBatchesExceedingMaxBytes(t *testing.T) {
+	var batches [][]*Event
+	batcher := NewBatcher(func(events []*Event) {
+		batches = append(batches, events)
+	})
+	batcher.MaxBytes = 100
+	batcher.MaxLatency = 0
+	batcher.Listen(New().WithField("message", strings.Repeat("a", 60)))
+	require.Len(t, batches, 0)
+	batcher.Listen(New().WithField("message", strings.Repeat("b", 60)))
+	require.Len(t, batches, 1)
+	require.Len(t, batches[0], 2)
+}
+
+func TestBatcherHandsOverBatchesAfterMaxLatency(t *testing.T) {
+	delivered := make(chan []*Event, 1)
+	batcher := NewBatcher(func(events []*Event) {
+		delivered <- events
+	})
+	batcher.MaxLatency = 10 * time.Millisecond
+	batcher.Listen(New().WithField("i", 0))
+	batcher.Listen(New().WithField("i", 1))
+	select {
+	case events := <-delivered:
+		require.Len(t, events, 2)
+	case <-time.After(time.Second):
+		t.Fatal("batch was not handed over")
+	}
+}
+
+type countingWriter struct {
+	bytes.Buffer
+	writes int
+	mutex  sync.Mutex
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.writes++
+	return w.Buffer.Write(p)
+}
+
+func TestWriterWritesBatchWithSingleWrite(t *testing.T) {
+	out := &countingWriter{}
+	writer := NewWriter()
+	writer.Out = out
+	batcher := NewSinkBatcher(writer)
+	for i := 0; i < 5; i++ {
+		batcher.Listen(New().WithField("i", i))
+	}
+	require.NoError(t, batcher.Close(context.Background()))
+	require.Equal(t, 1, out.writes)
+	require.Equal(t, "{\"i\":0}\n{\"i\":1}\n{\"i\":2}\n{\"i\":3}\n{\"i\":4}\n", out.String())
+	batcher.Listen(New().WithField("i", 5))
+	require.NoError(t, batcher.Flush(context.Background()))
+	require.Equal(t, 1, out.writes)
+}
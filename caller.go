@@ -0,0 +1,52 @@
+package telemetry
+
+import (
+	"runtime"
+)
+
+var CallerKey = "caller"
+var StacktraceKey = "stacktrace"
+
+// Maximum number of frames in a captured stack trace.
+const maxStacktraceDepth = 32
+
+// Adds the caller to the Event under CallerKey as Fields with the "function", "file", and "line" of the call, and
+// returns new Event with the caller included. Skip is the number of frames to skip above the function calling
+// WithCaller, so that wrappers, such as a logger's methods, can report their own caller.
+func (event *Event) WithCaller(skip int) *Event {
+	frames := callers(skip+1, 1)
+	if len(frames) == 0 {
+		return event.WithFields(nil) // make a copy
+	}
+	return event.WithField(CallerKey, frames[0])
+}
+
+// Adds the stack trace to the Event under StacktraceKey as a slice of Fields with the "function", "file", and
+// "line" of each call, innermost first, and returns new Event with the stack trace included. Skip is the number of
+// frames to skip above the function calling WithStacktrace, as with WithCaller.
+func (event *Event) WithStacktrace(skip int) *Event {
+	return event.WithField(StacktraceKey, callers(skip+1, maxStacktraceDepth))
+}
+
+// Returns up to `depth` frames of the stack after skipping `skip` frames, the first of which is the function calling
+// callers.
+func callers(skip int, depth int) []Fields {
+	pcs := make([]uintptr, skip+depth)
+	n := runtime.Callers(2, pcs) // skip runtime.Callers and callers
+	iterator := runtime.CallersFrames(pcs[:n])
+	frames := make([]Fields, 0, depth)
+	for i := 0; len(frames) < depth; i++ {
+		frame, more := iterator.Next()
+		if i >= skip && frame.Function != "" {
+			frames = append(frames, Fields{
+				"function": frame.Function,
+				"file":     frame.File,
+				"line":     frame.Line,
+			})
+		}
+		if !more {
+			break
+		}
+	}
+	return frames
+}
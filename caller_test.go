@@ -0,0 +1,40 @@
+package telemetry
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTelemetryCapturesCaller(t *testing.T) {
+	_telemetry := New()
+	event := _telemetry.WithField("key", "value")
+	_, exists := event.Get(CallerKey)
+	require.False(t, exists)
+
+	_telemetry.CaptureCaller = true
+	_telemetry.CaptureStacktrace = true
+	for _, event := range []*Event{NewEvent(_telemetry), _telemetry.WithField("key", "value")} {
+		function, _ := event.GetString(CallerKey + ".function")
+		require.Equal(t, "github.com/tristanls/telemetry.TestTelemetryCapturesCaller", function)
+		file, _ := event.GetString(CallerKey + ".file")
+		require.True(t, strings.HasSuffix(file, "/caller_test.go"), file)
+		_, ok := event.GetInt(CallerKey + ".line")
+		require.True(t, ok)
+
+		value, _ := event.Get(StacktraceKey)
+		stacktrace := value.([]Fields)
+		require.Equal(t, "github.com/tristanls/telemetry.TestTelemetryCapturesCaller", stacktrace[0]["function"])
+		require.Equal(t, "testing.tRunner", stacktrace[1]["function"])
+	}
+}
+
+func TestWithCallerSkipsFrames(t *testing.T) {
+	wrapper := func() *Event {
+		return NewEvent(New()).WithCaller(1)
+	}
+	event := wrapper()
+	function, _ := event.GetString(CallerKey + ".function")
+	require.Equal(t, "github.com/tristanls/telemetry.TestWithCallerSkipsFrames", function)
+}
@@ -0,0 +1,45 @@
+package telemetry
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the time at which events are emitted.
+type Clock interface {
+	Now() time.Time
+}
+
+// Creates new FakeClock stopped at the specified time.
+func NewFakeClock(t time.Time) *FakeClock {
+	return &FakeClock{now: t}
+}
+
+// FakeClock is a Clock that only moves when told to, for use in tests.
+type FakeClock struct {
+	now   time.Time
+	mutex sync.Mutex
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.now
+}
+
+// Sets the time of the clock.
+func (c *FakeClock) Set(t time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.now = t
+}
+
+// Moves the clock forward by the duration.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.now = c.now.Add(d)
+}
@@ -0,0 +1,111 @@
+package telemetry
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Key of the field summarizing events suppressed by a Deduplicator. It holds Fields with the number of suppressed
+// events under "count" and the timestamps of the first and last of them under "first" and "last".
+var RepeatsKey = "repeats"
+
+// Creates new Deduplicator that identifies events by the values of the `keys` fields, by default "level",
+// "message", and ErrorKey, and suppresses repeats within `window`.
+func NewDeduplicator(window time.Duration, keys ...string) *Deduplicator {
+	if len(keys) == 0 {
+		keys = []string{"level", "message", ErrorKey}
+	}
+	return &Deduplicator{
+		Key:     FieldKey(keys...),
+		Window:  window,
+		windows: make(map[string]*repeats),
+	}
+}
+
+// Deduplicator is a Middleware that suppresses repeated events. The first event with a given fingerprint passes
+// through and opens a window; repeats within the window are suppressed. When the window closes, if any events were
+// suppressed, the last of them passes through with a RepeatsKey field summarizing the suppressed events. Flushing or
+// closing the Emitter closes all open windows.
+type Deduplicator struct {
+	// Returns the fingerprint of an event.
+	Key func(*Event) string
+
+	// Length of the window in which repeats are suppressed.
+	Window time.Duration
+
+	windows map[string]*repeats
+
+	mutex sync.Mutex
+}
+
+// Events suppressed within a window.
+type repeats struct {
+	count int
+	first interface{}
+	last  *Event
+	next  Listener
+	timer *time.Timer
+}
+
+func (d *Deduplicator) Handle(event *Event, next Listener) {
+	key := d.Key(event)
+
+	d.mutex.Lock()
+	r, exists := d.windows[key]
+	if !exists {
+		r = &repeats{}
+		d.windows[key] = r
+		r.timer = time.AfterFunc(d.Window, func() {
+			d.close(key, r)
+		})
+		d.mutex.Unlock()
+		next(event)
+		return
+	}
+	if r.count == 0 {
+		r.first, _ = event.Get(TimestampKey)
+	}
+	r.count++
+	r.last = event
+	r.next = next
+	d.mutex.Unlock()
+}
+
+// Closes all open windows, passing through their summaries.
+func (d *Deduplicator) Flush(ctx context.Context) error {
+	d.mutex.Lock()
+	windows := d.windows
+	d.windows = make(map[string]*repeats)
+	d.mutex.Unlock()
+	for _, r := range windows {
+		r.timer.Stop()
+		d.summarize(r)
+	}
+	return nil
+}
+
+// Closes the window unless it was closed already.
+func (d *Deduplicator) close(key string, r *repeats) {
+	d.mutex.Lock()
+	if d.windows[key] != r {
+		d.mutex.Unlock()
+		return
+	}
+	delete(d.windows, key)
+	d.mutex.Unlock()
+	d.summarize(r)
+}
+
+// Passes through the last suppressed event with a summary of the suppressed events, if there were any.
+func (d *Deduplicator) summarize(r *repeats) {
+	if r.count == 0 {
+		return
+	}
+	last, _ := r.last.Get(TimestampKey)
+	r.next(r.last.WithField(RepeatsKey, Fields{
+		"count": r.count,
+		"first": r.first,
+		"last":  last,
+	}))
+}
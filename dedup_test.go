@@ -0,0 +1,54 @@
+package telemetry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeduplicatorSummarizesRepeats(t *testing.T) {
+	emitter := NewEmitter()
+	emitter.Use(NewDeduplicator(time.Hour))
+	var received []map[string]interface{}
+	emitter.AddListener(func(event *Event) {
+		received = append(received, event.Marshal())
+	})
+	for i := 0; i < 4; i++ {
+		emitter.Emit(New().WithFields(Fields{
+			"level":      "error",
+			"message":    "connection refused",
+			TimestampKey: i,
+		}))
+	}
+	emitter.Emit(New().WithFields(Fields{"level": "error", "message": "timeout"}))
+	require.Len(t, received, 2)
+	require.Equal(t, "connection refused", received[0]["message"])
+	require.Nil(t, received[0][RepeatsKey])
+	require.Equal(t, "timeout", received[1]["message"])
+
+	require.NoError(t, emitter.Flush(context.Background()))
+	require.Len(t, received, 3)
+	require.Equal(t, "connection refused", received[2]["message"])
+	require.Equal(t, Fields{"count": 3, "first": 1, "last": 3}, received[2][RepeatsKey])
+}
+
+func TestDeduplicatorClosesWindow(t *testing.T) {
+	deduplicator := NewDeduplicator(10*time.Millisecond, "message")
+	received := make(chan *Event, 3)
+	listener := Chain([]Middleware{deduplicator}, func(event *Event) {
+		received <- event
+	})
+	listener(New().WithField("message", "hello"))
+	listener(New().WithField("message", "hello"))
+	require.Nil(t, field((<-received), RepeatsKey))
+	select {
+	case event := <-received:
+		require.Equal(t, 1, field(event, RepeatsKey).(Fields)["count"])
+	case <-time.After(time.Second):
+		t.Fatal("summary was not emitted")
+	}
+	listener(New().WithField("message", "hello"))
+	require.Nil(t, field((<-received), RepeatsKey))
+}
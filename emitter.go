@@ -1,52 +1,642 @@
 package telemetry
 
 import (
+	"context"
+	"fmt"
+	"runtime/debug"
 	"sync"
-	"time"
+	"sync/atomic"
 )
 
 type Listener func(*Event)
 
+// ContextListener is a Listener that also receives the context the event was emitted with, so that it can respect
+// cancellation and read request-scoped values such as trace IDs.
+type ContextListener func(context.Context, *Event)
+
+// Extractor returns fields to copy from the context into an event emitted with that context.
+type Extractor func(context.Context) Fields
+
+// Overflow policy determines what an asynchronous Emitter does with an event when its queue is full.
+type OverflowPolicy uint8
+
+const (
+	// Block the emitting goroutine until there is room in the queue.
+	Block OverflowPolicy = iota
+	// Drop the event being emitted.
+	DropNewest
+	// Drop the oldest queued event to make room for the event being emitted.
+	DropOldest
+)
+
 func NewEmitter() *Emitter {
 	return &Emitter{}
 }
 
+// Creates new asynchronous Emitter. Emitted events are placed on a queue holding up to `queueSize` events and
+// are delivered to listeners by `workers` goroutines, so a slow listener does not stall the emitting goroutine.
+// When the queue is full, `overflow` policy determines whether Emit blocks or which event is dropped. With more
+// than one worker, listeners may observe events out of order.
+func NewAsyncEmitter(queueSize int, workers int, overflow OverflowPolicy) *Emitter {
+	if queueSize < 1 {
+		queueSize = 1
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	e := &Emitter{
+		queue:    make(chan queued, queueSize),
+		overflow: overflow,
+	}
+	for i := 0; i < workers; i++ {
+		go e.work()
+	}
+	return e
+}
+
 type Emitter struct {
-	// Collection of Listeners listening for Telemetry Events
-	listeners []Listener
+	// Called with internal errors, such as a Listener panicking, instead of letting them unwind into the emitting
+	// code. Default writes the error to os.Stderr, or for a child Emitter, reports it to the parent. Set before
+	// emitting events.
+	ErrorHandler func(*InternalError)
+
+	// Disables recovery from Listener panics so that they unwind into the emitting code, which is useful in tests.
+	// A panic in an asynchronous Emitter's worker crashes the process when recovery is disabled.
+	DisableRecovery bool
+
+	// Emitter that events are forwarded to after delivery to own listeners. Nil unless this is a child Emitter.
+	parent *Emitter
+
+	// Fields and provenance a child Emitter stamps on every event.
+	fields     Fields
+	provenance Fields
+
+	// Child Emitters with listeners or middleware of their own, which are flushed and closed along with this
+	// Emitter. The slice is replaced, never modified in place.
+	children []*Emitter
+
+	// Collection of Subscriptions listening for Telemetry Events. The slice is replaced, never modified in place,
+	// so that a snapshot taken under the mutex can be iterated without holding it.
+	subscriptions []*Subscription
+
+	// Identifier to assign to the next Subscription.
+	nextID uint64
+
+	// Middleware in the order it was added. The slice is replaced, never modified in place.
+	middleware []Middleware
+
+	// Extractors of fields from the context. The slice is replaced, never modified in place.
+	extractors []Extractor
+
+	// Queue of events awaiting delivery by workers. Nil if the Emitter is synchronous.
+	queue chan queued
+
+	// What to do when the queue is full.
+	overflow OverflowPolicy
+
+	// Count of events emitted.
+	emitted uint64
+
+	// Count of events dropped because the queue was full or the Emitter was closed.
+	dropped uint64
+
+	// Number of queued events not yet delivered, and a channel closed once that number drops to zero.
+	pending      int
+	drained      chan struct{}
+	pendingMutex sync.Mutex
+
+	// Set once the Emitter is closed. Held for reading while enqueueing so that the queue can be closed safely.
+	closed         bool
+	lifecycleMutex sync.RWMutex
 
 	mutex sync.Mutex
 }
 
-func (e *Emitter) AddListener(listener Listener) {
+// An event waiting in the queue together with the context it was emitted with.
+type queued struct {
+	ctx   context.Context
+	event *Event
+}
+
+// Adds the listener to the Emitter and returns a Subscription that can be used to remove it. If any matchers are
+// provided, the listener only receives events matched by all of them.
+func (e *Emitter) AddListener(listener Listener, matchers ...Matcher) *Subscription {
+	return e.subscribe(func(ctx context.Context, event *Event) {
+		listener(event)
+	}, functionName(listener), nil, matchers)
+}
+
+// Adds the context listener to the Emitter and returns a Subscription that can be used to remove it. If any
+// matchers are provided, the listener only receives events matched by all of them.
+func (e *Emitter) AddContextListener(listener ContextListener, matchers ...Matcher) *Subscription {
+	return e.subscribe(listener, functionName(listener), nil, matchers)
+}
+
+// Adds the sink to the Emitter and returns a Subscription that can be used to remove it. If any matchers are
+// provided, the sink only receives events matched by all of them. Flushing or closing the Emitter flushes or
+// closes the sink as well. If the sink has a `ListenContext(context.Context, *Event)` method, it is called instead
+// of Listen.
+func (e *Emitter) AddSink(sink Sink, matchers ...Matcher) *Subscription {
+	if sink, ok := sink.(interface {
+		ListenContext(context.Context, *Event)
+	}); ok {
+		return e.subscribe(sink.ListenContext, fmt.Sprintf("%T", sink), sink.(Sink), matchers)
+	}
+	return e.subscribe(func(ctx context.Context, event *Event) {
+		sink.Listen(event)
+	}, fmt.Sprintf("%T", sink), sink, matchers)
+}
+
+func (e *Emitter) subscribe(listener ContextListener, name string, sink Sink, matchers []Matcher) *Subscription {
+	e.register()
 	e.mutex.Lock()
 	defer e.mutex.Unlock()
 
-	e.listeners = append(e.listeners, listener)
+	e.nextID++
+	subscription := &Subscription{
+		id:      e.nextID,
+		name:    name,
+		emitter: e,
+		handler: listener,
+		sink:    sink,
+	}
+	switch len(matchers) {
+	case 0:
+	case 1:
+		subscription.matcher = matchers[0]
+	default:
+		subscription.matcher = MatchAll(matchers...)
+	}
+	subscriptions := make([]*Subscription, len(e.subscriptions), len(e.subscriptions)+1)
+	copy(subscriptions, e.subscriptions)
+	e.subscriptions = append(subscriptions, subscription)
+	return subscription
 }
 
+// RemoveListener has no effect.
+//
+// Deprecated: Functions cannot be compared in Go, so the listener to remove cannot be identified. Use the
+// Subscription returned by AddListener instead.
 func (e *Emitter) RemoveListener(listener Listener) {
+}
+
+// Removes the subscription from the collection of subscriptions.
+func (e *Emitter) remove(subscription *Subscription) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	for i, s := range e.subscriptions {
+		if s == subscription {
+			subscriptions := make([]*Subscription, 0, len(e.subscriptions)-1)
+			subscriptions = append(subscriptions, e.subscriptions[:i]...)
+			e.subscriptions = append(subscriptions, e.subscriptions[i+1:]...)
+			return
+		}
+	}
+}
+
+// Returns the current collection of subscriptions. The returned slice must not be modified.
+func (e *Emitter) snapshot() []*Subscription {
 	e.mutex.Lock()
 	defer e.mutex.Unlock()
 
-	for i, l := range e.listeners {
-		if &l == &listener {
-			e.listeners = append(e.listeners[:i], e.listeners[i+1:]...)
+	return e.subscriptions
+}
+
+// Returns the number of events dropped because the queue of an asynchronous Emitter was full or because they were
+// emitted after the Emitter was closed.
+func (e *Emitter) Dropped() uint64 {
+	return atomic.LoadUint64(&e.dropped)
+}
+
+// Creates a child Emitter that adds `fields` (unless the event already has them) and a `provenance` entry to every
+// event, delivers the event to its own listeners, and then forwards it to this Emitter. Either argument can be nil.
+// Children are cheap to create, so each subsystem can have its own without rebuilding listener wiring. Provenance
+// of nested children is ordered from the outermost to the innermost child, followed by the event's own provenance.
+// Closing a child closes its own sinks but not the parent, while flushing or closing the parent flushes or closes
+// the child first.
+func (e *Emitter) Child(fields Fields, provenance Fields) *Emitter {
+	return &Emitter{
+		DisableRecovery: e.DisableRecovery,
+		parent:          e,
+		fields:          fields,
+		provenance:      provenance,
+	}
+}
+
+// Adds a child Emitter to the children of its parent, and the parent to its own parent's, so that flushing or
+// closing the parent reaches the child's sinks and middleware. Children are only added once they have listeners or
+// middleware, so that children created and dropped for each request aren't kept.
+func (e *Emitter) register() {
+	parent := e.parent
+	if parent == nil {
+		return
+	}
+	parent.mutex.Lock()
+	for _, child := range parent.children {
+		if child == e {
+			parent.mutex.Unlock()
+			return
+		}
+	}
+	children := make([]*Emitter, len(parent.children), len(parent.children)+1)
+	copy(children, parent.children)
+	parent.children = append(children, e)
+	parent.mutex.Unlock()
+	parent.register()
+}
+
+// Removes a closed child Emitter from the children of its parent.
+func (e *Emitter) unregister() {
+	parent := e.parent
+	if parent == nil {
+		return
+	}
+	parent.mutex.Lock()
+	defer parent.mutex.Unlock()
+
+	for i, child := range parent.children {
+		if child == e {
+			children := make([]*Emitter, 0, len(parent.children)-1)
+			children = append(children, parent.children[:i]...)
+			parent.children = append(children, parent.children[i+1:]...)
 			return
 		}
 	}
 }
 
+// Returns the current children.
+func (e *Emitter) snapshotChildren() []*Emitter {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	return e.children
+}
+
+// Returns a snapshot of delivery statistics of the Emitter and each of its Subscriptions.
+func (e *Emitter) Stats() EmitterStats {
+	e.pendingMutex.Lock()
+	queued := e.pending
+	e.pendingMutex.Unlock()
+	subscriptions := e.snapshot()
+	stats := EmitterStats{
+		Emitted:       atomic.LoadUint64(&e.emitted),
+		Dropped:       atomic.LoadUint64(&e.dropped),
+		Queued:        queued,
+		Subscriptions: make([]SubscriptionStats, len(subscriptions)),
+	}
+	for i, subscription := range subscriptions {
+		stats.Subscriptions[i] = subscription.Stats()
+	}
+	return stats
+}
+
 // Emits the event, adding timestamp if not already present.
 func (e *Emitter) Emit(event *Event) {
-	var ev *Event
-	_, exists := event.data[TimestampKey]
-	if !exists {
-		ev = event.WithField(TimestampKey, time.Now().UTC().Format(event.telemetry.TimestampLayout))
+	e.EmitContext(context.Background(), event)
+}
+
+// Emits the event, adding timestamp if not already present and fields returned by extractors. The context is
+// passed on to context listeners and, for a child Emitter, to the parent.
+func (e *Emitter) EmitContext(ctx context.Context, event *Event) {
+	atomic.AddUint64(&e.emitted, 1)
+	ev := event
+	if _, exists := event.Get(TimestampKey); !exists {
+		ev = event.WithField(TimestampKey, event.telemetry.now())
+	}
+	if e.parent != nil {
+		ev = e.stamp(ev)
+	}
+	e.mutex.Lock()
+	middleware, extractors := e.middleware, e.extractors
+	e.mutex.Unlock()
+	for _, extract := range extractors {
+		if fields := extract(ctx); fields != nil {
+			ev = ev.withDefaults(fields)
+		}
+	}
+	if middleware == nil {
+		e.publish(ctx, ev)
+		return
+	}
+	if !e.DisableRecovery {
+		defer e.recover(nil, ev)
+	}
+	Chain(middleware, func(event *Event) {
+		e.publish(ctx, event)
+	})(ev)
+}
+
+// Returns the event with an ID and sequence number, if its Telemetry assigns them, leaving out those it already
+// has, so that events forwarded by a child Emitter keep them. They are assigned after middleware, so that every
+// event middleware publishes, such as each part of a split event, gets its own.
+func (e *Emitter) identify(event *Event) *Event {
+	if !event.telemetry.AssignID && !event.telemetry.AssignSequence {
+		return event
+	}
+	stamps := make(Fields, 2)
+	if event.telemetry.AssignID {
+		if _, exists := event.Get(IDKey); !exists {
+			stamps[IDKey] = newID(event.telemetry.now())
+		}
+	}
+	if event.telemetry.AssignSequence {
+		if _, exists := event.Get(SequenceKey); !exists {
+			stamps[SequenceKey] = nextSequence()
+		}
+	}
+	return event.WithFields(stamps)
+}
+
+// Adds an extractor whose fields are copied into every event emitted with a context. Fields already present in
+// the event take precedence over extracted ones.
+func (e *Emitter) AddExtractor(extractor Extractor) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	extractors := make([]Extractor, 0, len(e.extractors)+1)
+	extractors = append(extractors, e.extractors...)
+	e.extractors = append(extractors, extractor)
+}
+
+// Adds middleware that every emitted event passes through, in order, before it is delivered to listeners.
+func (e *Emitter) Use(middleware ...Middleware) {
+	e.register()
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	combined := make([]Middleware, 0, len(e.middleware)+len(middleware))
+	combined = append(combined, e.middleware...)
+	e.middleware = append(combined, middleware...)
+}
+
+// Adds the child Emitter's fields and provenance to the event.
+func (e *Emitter) stamp(event *Event) *Event {
+	var provenance []Fields
+	if e.provenance != nil {
+		provenance = make([]Fields, 0, len(event.provenance)+1)
+		provenance = append(provenance, e.provenance)
+		provenance = append(provenance, event.provenance...)
 	} else {
-		ev = event.WithFields(nil) // make a copy
+		provenance = event.provenance
+	}
+	event = event.withDefaults(e.fields)
+	return &Event{telemetry: event.telemetry, provenance: provenance, data: event.data}
+}
+
+// Queues the event or delivers it to listeners, unless the Emitter is closed. A child Emitter then forwards the
+// event to its parent.
+func (e *Emitter) publish(ctx context.Context, event *Event) {
+	event = e.identify(event)
+	e.lifecycleMutex.RLock()
+	if e.closed {
+		e.lifecycleMutex.RUnlock()
+		atomic.AddUint64(&e.dropped, 1)
+		return
+	}
+	if e.queue != nil {
+		e.enqueue(queued{ctx: ctx, event: event})
+		e.lifecycleMutex.RUnlock()
+		return
+	}
+	e.lifecycleMutex.RUnlock()
+	e.dispatch(ctx, event)
+	if e.parent != nil {
+		e.parent.EmitContext(ctx, event)
+	}
+}
+
+// Listen emits the event. It implements the Sink interface so that an Emitter can be added to another Emitter.
+func (e *Emitter) Listen(event *Event) {
+	e.Emit(event)
+}
+
+// ListenContext emits the event with the context. It is used instead of Listen when an Emitter is added to another
+// Emitter.
+func (e *Emitter) ListenContext(ctx context.Context, event *Event) {
+	e.EmitContext(ctx, event)
+}
+
+// Flushes child Emitters, releases events held by middleware, waits for queued events to be delivered and then
+// flushes every sink added to the Emitter. Returns a DrainError listing the queue and the sinks that failed to drain before the context was
+// done.
+func (e *Emitter) Flush(ctx context.Context) error {
+	var errs DrainError
+	for _, child := range e.snapshotChildren() {
+		if err, ok := child.Flush(ctx).(DrainError); ok {
+			errs = append(errs, err...)
+		}
+	}
+	if err := e.flushMiddleware(ctx); err != nil {
+		errs = append(errs, SinkError{Err: err})
+	}
+	if err := e.drain(ctx); err != nil {
+		errs = append(errs, SinkError{Err: err})
+	}
+	for _, subscription := range e.snapshot() {
+		if err := subscription.flushMiddleware(ctx); err != nil {
+			errs = append(errs, SinkError{Subscription: subscription, Err: err})
+		}
+		if subscription.sink == nil {
+			continue
+		}
+		if err := subscription.sink.Flush(ctx); err != nil {
+			errs = append(errs, SinkError{Subscription: subscription, Err: err})
+		}
+	}
+	if errs != nil {
+		return errs
+	}
+	return nil
+}
+
+// Closes child Emitters, releases events held by middleware, stops accepting events, waits for queued events to be
+// delivered, stops the workers and then closes every sink added to the Emitter. Events emitted after Close are dropped. Returns a
+// DrainError listing the queue and the sinks that failed to drain before the context was done.
+func (e *Emitter) Close(ctx context.Context) error {
+	e.lifecycleMutex.RLock()
+	closed := e.closed
+	e.lifecycleMutex.RUnlock()
+	if closed {
+		return nil
+	}
+	var errs DrainError
+	for _, child := range e.snapshotChildren() {
+		if err, ok := child.Close(ctx).(DrainError); ok {
+			errs = append(errs, err...)
+		}
+	}
+	if err := e.flushMiddleware(ctx); err != nil {
+		errs = append(errs, SinkError{Err: err})
+	}
+	e.lifecycleMutex.Lock()
+	if e.closed {
+		e.lifecycleMutex.Unlock()
+		return nil
+	}
+	e.closed = true
+	e.lifecycleMutex.Unlock()
+
+	if err := e.drain(ctx); err != nil {
+		errs = append(errs, SinkError{Err: err})
+	}
+	if e.queue != nil {
+		close(e.queue) // no more senders once closed is set, workers exit once the queue is empty
+	}
+	for _, subscription := range e.snapshot() {
+		if err := subscription.flushMiddleware(ctx); err != nil {
+			errs = append(errs, SinkError{Subscription: subscription, Err: err})
+		}
+		if subscription.sink == nil {
+			continue
+		}
+		if err := subscription.sink.Close(ctx); err != nil {
+			errs = append(errs, SinkError{Subscription: subscription, Err: err})
+		}
+	}
+	e.unregister()
+	if errs != nil {
+		return errs
+	}
+	return nil
+}
+
+// Flushes middleware that holds on to events.
+func (e *Emitter) flushMiddleware(ctx context.Context) error {
+	e.mutex.Lock()
+	middleware := e.middleware
+	e.mutex.Unlock()
+	return flushMiddleware(ctx, middleware)
+}
+
+// Waits until there are no queued events or the context is done.
+func (e *Emitter) drain(ctx context.Context) error {
+	e.pendingMutex.Lock()
+	if e.pending == 0 {
+		e.pendingMutex.Unlock()
+		return nil
+	}
+	drained := e.drained
+	e.pendingMutex.Unlock()
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Records that an event is about to be queued.
+func (e *Emitter) begin() {
+	e.pendingMutex.Lock()
+	defer e.pendingMutex.Unlock()
+
+	if e.pending == 0 {
+		e.drained = make(chan struct{})
+	}
+	e.pending++
+}
+
+// Records that a queued event was delivered or dropped.
+func (e *Emitter) end() {
+	e.pendingMutex.Lock()
+	defer e.pendingMutex.Unlock()
+
+	e.pending--
+	if e.pending == 0 {
+		close(e.drained)
+	}
+}
+
+// Places the event on the queue according to the overflow policy.
+func (e *Emitter) enqueue(event queued) {
+	e.begin()
+	switch e.overflow {
+	case DropNewest:
+		select {
+		case e.queue <- event:
+		default:
+			atomic.AddUint64(&e.dropped, 1)
+			e.end()
+		}
+	case DropOldest:
+		for {
+			select {
+			case e.queue <- event:
+				return
+			default:
+			}
+			select {
+			case <-e.queue:
+				atomic.AddUint64(&e.dropped, 1)
+				e.end()
+			default:
+			}
+		}
+	default:
+		e.queue <- event
+	}
+}
+
+// Delivers queued events to listeners.
+func (e *Emitter) work() {
+	for queued := range e.queue {
+		e.dispatch(queued.ctx, queued.event)
+		e.end()
+	}
+}
+
+// Delivers the event to every subscription. A panicking Listener is reported as an internal error and does not
+// prevent delivery to the remaining ones.
+func (e *Emitter) dispatch(ctx context.Context, event *Event) {
+	for _, subscription := range e.snapshot() {
+		e.deliver(ctx, subscription, event)
+	}
+}
+
+func (e *Emitter) deliver(ctx context.Context, subscription *Subscription, event *Event) {
+	if !e.DisableRecovery {
+		defer e.recover(subscription, event)
+	}
+	subscription.handle(ctx, event)
+}
+
+// Calls the subscription's handler, recovering from panics.
+func (e *Emitter) invoke(ctx context.Context, subscription *Subscription, event *Event) {
+	if !e.DisableRecovery {
+		defer e.recover(subscription, event)
+	}
+	subscription.call(ctx, event)
+}
+
+// Recovers from a Listener or, if subscription is nil, Middleware panic and reports it as an internal error. Must be
+// deferred.
+func (e *Emitter) recover(subscription *Subscription, event *Event) {
+	value := recover()
+	if value == nil {
+		return
+	}
+	if subscription != nil {
+		atomic.AddUint64(&subscription.panicked, 1)
+	}
+	e.Report(&InternalError{
+		Subscription: subscription,
+		Event:        event,
+		Value:        value,
+		Stack:        debug.Stack(),
+	})
+}
+
+// Reports the internal error to the ErrorHandler, or for a child Emitter without one, to the parent. Middleware can
+// use it to report failures that don't warrant a panic, such as rejecting an event.
+func (e *Emitter) Report(err *InternalError) {
+	if e.ErrorHandler != nil {
+		e.ErrorHandler(err)
+		return
 	}
-	for _, handle := range e.listeners {
-		handle(ev)
+	if e.parent != nil {
+		e.parent.Report(err)
+		return
 	}
+	WriteInternalError(err)
 }
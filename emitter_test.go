@@ -0,0 +1,513 @@
+package telemetry
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAsyncEmitterDeliversAllEventsWhenBlocking(t *testing.T) {
+	emitter := NewAsyncEmitter(2, 3, Block)
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	received := 0
+	emitter.AddListener(func(event *Event) {
+		mutex.Lock()
+		received++
+		mutex.Unlock()
+		wg.Done()
+	})
+	wg.Add(100)
+	for i := 0; i < 100; i++ {
+		emitter.Emit(New().WithField("i", i))
+	}
+	wg.Wait()
+	require.Equal(t, 100, received)
+	require.Equal(t, uint64(0), emitter.Dropped())
+}
+
+func TestAsyncEmitterDropsNewestWhenFull(t *testing.T) {
+	emitter := NewAsyncEmitter(1, 1, DropNewest)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var received []interface{}
+	done := make(chan struct{})
+	emitter.AddListener(func(event *Event) {
+		if field(event, "i") == 0 {
+			close(started)
+			<-release
+		}
+		received = append(received, field(event, "i"))
+		if field(event, "i") == 1 {
+			close(done)
+		}
+	})
+	emitter.Emit(New().WithField("i", 0))
+	<-started
+	emitter.Emit(New().WithField("i", 1)) // queued
+	emitter.Emit(New().WithField("i", 2)) // dropped
+	emitter.Emit(New().WithField("i", 3)) // dropped
+	close(release)
+	<-done
+	require.Equal(t, []interface{}{0, 1}, received)
+	require.Equal(t, uint64(2), emitter.Dropped())
+}
+
+func TestAsyncEmitterDropsOldestWhenFull(t *testing.T) {
+	emitter := NewAsyncEmitter(1, 1, DropOldest)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var received []interface{}
+	done := make(chan struct{})
+	emitter.AddListener(func(event *Event) {
+		if field(event, "i") == 0 {
+			close(started)
+			<-release
+		}
+		received = append(received, field(event, "i"))
+		if field(event, "i") == 3 {
+			close(done)
+		}
+	})
+	emitter.Emit(New().WithField("i", 0))
+	<-started
+	emitter.Emit(New().WithField("i", 1)) // dropped
+	emitter.Emit(New().WithField("i", 2)) // dropped
+	emitter.Emit(New().WithField("i", 3)) // queued
+	close(release)
+	<-done
+	require.Equal(t, []interface{}{0, 3}, received)
+	require.Equal(t, uint64(2), emitter.Dropped())
+}
+
+func TestUnsubscribeStopsDelivery(t *testing.T) {
+	emitter := NewEmitter()
+	var first, second int
+	subscription := emitter.AddListener(func(event *Event) {
+		first++
+	})
+	emitter.AddListener(func(event *Event) {
+		second++
+	})
+	emitter.Emit(New().WithField("i", 0))
+	subscription.Unsubscribe()
+	subscription.Unsubscribe()
+	emitter.Emit(New().WithField("i", 1))
+	require.Equal(t, 1, first)
+	require.Equal(t, 2, second)
+}
+
+func TestUnsubscribeDuringEmit(t *testing.T) {
+	emitter := NewEmitter()
+	var subscription *Subscription
+	var calls int
+	emitter.AddListener(func(event *Event) {
+		subscription.Unsubscribe()
+	})
+	subscription = emitter.AddListener(func(event *Event) {
+		calls++
+	})
+	emitter.Emit(New().WithField("i", 0))
+	emitter.Emit(New().WithField("i", 1))
+	require.Equal(t, 0, calls)
+}
+
+func TestConcurrentSubscribeAndEmit(t *testing.T) {
+	emitter := NewEmitter()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				emitter.AddListener(func(event *Event) {}).Unsubscribe()
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				emitter.Emit(New().WithField("j", j))
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestCloseDeliversQueuedEventsToWriter(t *testing.T) {
+	emitter := NewAsyncEmitter(100, 2, Block)
+	var out bytes.Buffer
+	writer := NewWriter()
+	writer.Out = bufio.NewWriter(&out)
+	emitter.AddSink(writer)
+	for i := 0; i < 50; i++ {
+		emitter.Emit(New().WithField("i", i))
+	}
+	require.NoError(t, emitter.Close(context.Background()))
+	require.Equal(t, 50, strings.Count(out.String(), "\n"))
+	emitter.Emit(New().WithField("i", 50))
+	require.Equal(t, uint64(1), emitter.Dropped())
+}
+
+func TestFlushAndCloseCascadeToChildren(t *testing.T) {
+	parent := NewEmitter()
+	var out bytes.Buffer
+	writer := NewWriter()
+	writer.Out = bufio.NewWriter(&out)
+	child := parent.Child(Fields{"subsystem": "db"}, nil)
+	grandchild := child.Child(nil, nil)
+	grandchild.AddSink(writer)
+	parent.Child(nil, nil) // without listeners, not kept by the parent
+	require.Len(t, parent.snapshotChildren(), 1)
+
+	grandchild.Emit(New().WithField("message", "first"))
+	require.Equal(t, 0, out.Len())
+	require.NoError(t, parent.Flush(context.Background()))
+	require.Equal(t, 1, strings.Count(out.String(), "\n"))
+
+	grandchild.Emit(New().WithField("message", "second"))
+	require.NoError(t, parent.Close(context.Background()))
+	require.Equal(t, 2, strings.Count(out.String(), "\n"))
+	grandchild.Emit(New().WithField("message", "third"))
+	require.Equal(t, uint64(1), grandchild.Dropped())
+	require.Empty(t, parent.snapshotChildren())
+}
+
+type failingSink struct{}
+
+func (s *failingSink) Listen(event *Event) {}
+
+func (s *failingSink) Flush(ctx context.Context) error {
+	return errors.New("cannot flush")
+}
+
+func (s *failingSink) Close(ctx context.Context) error {
+	return errors.New("cannot close")
+}
+
+func TestFlushReportsFailedSinks(t *testing.T) {
+	emitter := NewEmitter()
+	emitter.AddSink(NewWriter())
+	subscription := emitter.AddSink(&failingSink{})
+	err := emitter.Flush(context.Background())
+	require.Error(t, err)
+	drainErr, ok := err.(DrainError)
+	require.True(t, ok)
+	require.Len(t, drainErr, 1)
+	require.Equal(t, subscription, drainErr[0].Subscription)
+	require.Equal(t, "cannot flush", drainErr[0].Err.Error())
+}
+
+func TestFlushHonorsDeadline(t *testing.T) {
+	emitter := NewAsyncEmitter(10, 1, Block)
+	release := make(chan struct{})
+	emitter.AddListener(func(event *Event) {
+		<-release
+	})
+	emitter.Emit(New().WithField("i", 0))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := emitter.Flush(ctx)
+	require.Error(t, err)
+	require.Equal(t, context.DeadlineExceeded, err.(DrainError)[0].Err)
+	close(release)
+	require.NoError(t, emitter.Flush(context.Background()))
+}
+
+func TestListenersReceiveMatchingEvents(t *testing.T) {
+	emitter := NewEmitter()
+	var metrics, requests, everything int
+	emitter.AddListener(func(event *Event) {
+		metrics++
+	}, MatchField("type", "metric"))
+	emitter.AddListener(func(event *Event) {
+		requests++
+	}, MatchField("type", "metric"), MatchPrefix("name", "request."))
+	emitter.AddListener(func(event *Event) {
+		everything++
+	})
+	emitter.Emit(New().WithFields(Fields{"type": "log", "name": "request.ignored"}))
+	emitter.Emit(New().WithFields(Fields{"type": "metric", "name": "request.latency"}))
+	emitter.Emit(New().WithFields(Fields{"type": "metric", "name": "cache.hits"}))
+	emitter.Emit(New().WithFields(Fields{"type": Fields{"not": "comparable"}}))
+	require.Equal(t, 2, metrics)
+	require.Equal(t, 1, requests)
+	require.Equal(t, 4, everything)
+}
+
+func TestPanickingListenerIsReported(t *testing.T) {
+	emitter := NewEmitter()
+	var reported *InternalError
+	emitter.ErrorHandler = func(err *InternalError) {
+		reported = err
+	}
+	subscription := emitter.AddListener(func(event *Event) {
+		panic("oops")
+	})
+	var calls int
+	emitter.AddListener(func(event *Event) {
+		calls++
+	})
+	require.NotPanics(t, func() {
+		emitter.Emit(New().WithField("i", 0))
+	})
+	require.Equal(t, 1, calls)
+	require.NotNil(t, reported)
+	require.Equal(t, subscription, reported.Subscription)
+	require.Equal(t, "oops", reported.Value)
+	require.Contains(t, string(reported.Stack), "TestPanickingListenerIsReported")
+	require.Contains(t, reported.Error(), "TestPanickingListenerIsReported")
+}
+
+func TestPanicPropagatesWhenRecoveryIsDisabled(t *testing.T) {
+	emitter := NewEmitter()
+	emitter.DisableRecovery = true
+	emitter.AddListener(func(event *Event) {
+		panic("oops")
+	})
+	require.PanicsWithValue(t, "oops", func() {
+		emitter.Emit(New().WithField("i", 0))
+	})
+}
+
+func TestChildEmitterStampsFieldsAndForwardsToParent(t *testing.T) {
+	parent := NewEmitter()
+	var parentEvents, childEvents []map[string]interface{}
+	parent.AddListener(func(event *Event) {
+		parentEvents = append(parentEvents, event.Marshal())
+	})
+	db := parent.Child(Fields{"subsystem": "db", "shard": 1}, Fields{"component": "db"})
+	db.AddListener(func(event *Event) {
+		childEvents = append(childEvents, event.Marshal())
+	})
+	replica := db.Child(Fields{"shard": 2}, Fields{"component": "replica"})
+	replica.Emit(New().WithProvenance(Fields{"file": "replica.go"}).WithField("message", "hello"))
+	parent.Emit(New().WithField("message", "parent only"))
+
+	require.Len(t, childEvents, 1)
+	require.Len(t, parentEvents, 2)
+	event := parentEvents[0]
+	require.Equal(t, childEvents[0], event)
+	require.Equal(t, "hello", event["message"])
+	require.Equal(t, "db", event["subsystem"])
+	require.Equal(t, 2, event["shard"])
+	require.Equal(t, []Fields{{"component": "db"}, {"component": "replica"}, {"file": "replica.go"}}, event[ProvenanceKey])
+	require.Equal(t, "parent only", parentEvents[1]["message"])
+	require.Nil(t, parentEvents[1]["subsystem"])
+}
+
+func TestEmitStampsTimeOfClock(t *testing.T) {
+	start := time.Date(2017, 2, 18, 22, 2, 35, 452123456, time.UTC)
+	clock := NewFakeClock(start)
+	_telemetry := New()
+	_telemetry.Clock = clock
+	emitter := NewEmitter()
+	var timestamps []interface{}
+	emitter.AddListener(func(event *Event) {
+		timestamp, _ := event.Get(TimestampKey)
+		timestamps = append(timestamps, timestamp)
+	})
+	emitter.Emit(_telemetry.WithField("message", "first"))
+	clock.Advance(time.Second)
+	emitter.Emit(_telemetry.WithField("message", "second"))
+	require.Equal(t, []interface{}{start, start.Add(time.Second)}, timestamps)
+
+	for layout, expected := range map[string]string{
+		"":               `"2017-02-18T22:02:35.452Z"`,
+		time.RFC3339Nano: `"2017-02-18T22:02:35.452123456Z"`,
+		UnixMilliseconds: `1487455355452`,
+		UnixNanoseconds:  `1487455355452123456`,
+	} {
+		serialized, err := (&JSONFormatter{TimestampLayout: layout}).Format(map[string]interface{}{
+			TimestampKey: start,
+			"nested":     Fields{"at": start},
+		})
+		require.NoError(t, err)
+		require.Equal(t, `{"nested":{"at":`+expected+`},"timestamp":`+expected+"}\n", string(serialized))
+	}
+}
+
+func TestWriterFallsBackToTimestampLayoutOfTelemetry(t *testing.T) {
+	start := time.Date(2017, 2, 18, 22, 2, 35, 452123456, time.UTC)
+	_telemetry := New()
+	_telemetry.Clock = NewFakeClock(start)
+	_telemetry.TimestampLayout = time.RFC3339Nano
+	emitter := NewEmitter()
+	var out bytes.Buffer
+	writer := NewWriter()
+	writer.Out = &out
+	emitter.AddSink(writer)
+	layoutWriter := NewWriter()
+	layoutWriter.Out = &out
+	layoutWriter.Formatter = NewLimits().Formatter(&JSONFormatter{TimestampLayout: UnixMilliseconds})
+	emitter.AddSink(layoutWriter)
+	emitter.Emit(_telemetry.WithField("message", "hello"))
+
+	require.Equal(t, `{"message":"hello","timestamp":"2017-02-18T22:02:35.452123456Z"}`+"\n"+
+		`{"message":"hello","timestamp":1487455355452}`+"\n", out.String())
+}
+
+func TestEmitAssignsIDsAndSequenceNumbers(t *testing.T) {
+	_telemetry := New()
+	_telemetry.AssignID = true
+	_telemetry.AssignSequence = true
+	parent := NewEmitter()
+	var events []*Event
+	parent.AddListener(func(event *Event) {
+		events = append(events, event)
+	})
+	child := parent.Child(Fields{"subsystem": "db"}, nil)
+	for i := 0; i < 100; i++ {
+		child.Emit(_telemetry.WithField("i", i))
+	}
+	parent.Emit(New().WithField("message", "not assigned"))
+
+	require.Len(t, events, 101)
+	for i := 1; i < 100; i++ {
+		previousID, _ := events[i-1].GetString(IDKey)
+		id, _ := events[i].GetString(IDKey)
+		require.Len(t, id, 26)
+		require.Less(t, previousID, id)
+		previous, _ := events[i-1].GetInt(SequenceKey)
+		sequence, _ := events[i].GetInt(SequenceKey)
+		require.Equal(t, previous+1, sequence)
+	}
+	_, exists := events[100].Get(IDKey)
+	require.False(t, exists)
+	_, exists = events[100].Get(SequenceKey)
+	require.False(t, exists)
+}
+
+func TestSplitEventsGetTheirOwnIDs(t *testing.T) {
+	_telemetry := New()
+	_telemetry.AssignID = true
+	_telemetry.AssignSequence = true
+	emitter := NewEmitter()
+	emitter.Use(MiddlewareFunc(func(event *Event, next Listener) {
+		next(event.WithField("part", 1))
+		next(event.WithField("part", 2))
+	}))
+	var events []*Event
+	emitter.AddListener(func(event *Event) {
+		events = append(events, event)
+	})
+	emitter.Emit(_telemetry.WithField("message", "split"))
+
+	require.Len(t, events, 2)
+	first, _ := events[0].GetString(IDKey)
+	second, _ := events[1].GetString(IDKey)
+	require.NotEmpty(t, first)
+	require.NotEqual(t, first, second)
+	firstSequence, _ := events[0].GetInt(SequenceKey)
+	secondSequence, _ := events[1].GetInt(SequenceKey)
+	require.Equal(t, firstSequence+1, secondSequence)
+}
+
+func TestIDsSortByTime(t *testing.T) {
+	var generator idGenerator
+	first := generator.generate(time.Date(2017, 2, 18, 22, 2, 35, 452000000, time.UTC))
+	require.Equal(t, "01B99KZ0HW", first[:10])
+	earlier := generator.generate(time.Date(2017, 2, 18, 22, 2, 35, 451000000, time.UTC))
+	require.Less(t, first, earlier) // monotonic even if the clock goes back
+	later := generator.generate(time.Date(2017, 2, 18, 22, 2, 35, 453000000, time.UTC))
+	require.Less(t, earlier, later)
+	require.Equal(t, "01B99KZ0HX", later[:10])
+}
+
+type traceIDKey struct{}
+
+func TestEmitContextPassesContextAndExtractsFields(t *testing.T) {
+	parent := NewAsyncEmitter(10, 1, Block)
+	parent.AddExtractor(func(ctx context.Context) Fields {
+		if traceID, ok := ctx.Value(traceIDKey{}).(string); ok {
+			return Fields{"trace_id": traceID}
+		}
+		return nil
+	})
+	received := make(chan string, 2)
+	parent.AddContextListener(func(ctx context.Context, event *Event) {
+		received <- ctx.Value(traceIDKey{}).(string) + " " + field(event, "trace_id").(string)
+	})
+	child := parent.Child(Fields{"subsystem": "http"}, nil)
+	ctx := context.WithValue(context.Background(), traceIDKey{}, "abc")
+	child.EmitContext(ctx, New().WithField("message", "hello"))
+	parent.EmitContext(ctx, New().WithField("trace_id", "explicit"))
+	require.NoError(t, parent.Close(context.Background()))
+	require.Equal(t, "abc abc", <-received)
+	require.Equal(t, "abc explicit", <-received)
+}
+
+func TestEmittedEventsDoNotShareStampedFields(t *testing.T) {
+	emitter := NewEmitter()
+	extracted := Fields{"a": 1, "b": 2, "c": 3, "d": 4, "e": 5}
+	emitter.AddExtractor(func(ctx context.Context) Fields {
+		return extracted
+	})
+	child := emitter.Child(Fields{"f": 1, "g": 2, "h": 3, "i": 4, "j": 5}, nil)
+	var events []*Event
+	emitter.AddListener(func(event *Event) {
+		events = append(events, event)
+	})
+	child.Emit(New().WithField("message", "hello"))
+	extracted["a"] = "changed"
+	child.fields["f"] = "changed"
+
+	require.Len(t, events, 1)
+	fields := events[0].Marshal()
+	require.Equal(t, 1, fields["a"])
+	require.Equal(t, 1, fields["f"])
+}
+
+func TestStatsCountDeliveries(t *testing.T) {
+	emitter := NewEmitter()
+	emitter.ErrorHandler = func(err *InternalError) {}
+	emitter.AddListener(func(event *Event) {
+		time.Sleep(time.Millisecond)
+	}, MatchField("type", "metric"))
+	emitter.AddListener(func(event *Event) {
+		if field(event, "i") == 1 {
+			panic("oops")
+		}
+	}).Use(Filter(MatchNot(MatchField("i", 2))))
+	for i := 0; i < 3; i++ {
+		emitter.Emit(New().WithFields(Fields{"type": "metric", "i": i}))
+	}
+	emitter.Emit(New().WithFields(Fields{"type": "log", "i": 3}))
+
+	stats := emitter.Stats()
+	require.Equal(t, uint64(4), stats.Emitted)
+	require.Equal(t, uint64(0), stats.Dropped)
+	require.Len(t, stats.Subscriptions, 2)
+	slow := stats.Subscriptions[0]
+	require.Equal(t, uint64(3), slow.Received)
+	require.Equal(t, uint64(3), slow.Delivered)
+	require.True(t, slow.P99 >= time.Millisecond)
+	require.True(t, slow.P50 <= slow.P99)
+	filtered := stats.Subscriptions[1]
+	require.Equal(t, uint64(4), filtered.Received)
+	require.Equal(t, uint64(3), filtered.Delivered)
+	require.Equal(t, uint64(1), filtered.Dropped)
+	require.Equal(t, uint64(1), filtered.Panicked)
+}
+
+func TestHistogramPercentiles(t *testing.T) {
+	var h histogram
+	for i := 1; i <= 100; i++ {
+		h.record(time.Duration(i) * time.Microsecond)
+	}
+	percentiles := h.percentiles(0.5, 0.99)
+	require.InEpsilon(t, float64(50*time.Microsecond), float64(percentiles[0]), 0.25)
+	require.InEpsilon(t, float64(99*time.Microsecond), float64(percentiles[1]), 0.25)
+	for i := 0; i < histogramBuckets; i++ {
+		if i >= 4 && i < 8 {
+			continue // unused
+		}
+		require.Equal(t, i, bucketIndex(bucketUpperBound(i)))
+	}
+}
@@ -0,0 +1,146 @@
+package telemetry
+
+import (
+	"encoding/hex"
+	"net"
+	"net/url"
+	"reflect"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// FieldMarshaler is implemented by field values that control how they are written, such as a struct leaving out
+// some of its fields. MarshalField returns the value to write instead, such as Fields or a string.
+type FieldMarshaler interface {
+	MarshalField() interface{}
+}
+
+// Maximum number of FieldMarshalers returning another FieldMarshaler that are marshaled, which guards against a
+// FieldMarshaler returning itself.
+const maxMarshalerDepth = 100
+
+// Returns whether the value is a nil pointer, which encoders and FieldMarshalers are not called with.
+func isNilPointer(value interface{}) bool {
+	v := reflect.ValueOf(value)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
+
+// Returns the value returned by the FieldMarshaler, marshaling FieldMarshalers it returns in turn. A nil pointer
+// is marshaled as nil rather than calling its MarshalField, and so is a value still marshaling to a FieldMarshaler
+// after maxMarshalerDepth calls.
+func marshalField(marshaler FieldMarshaler) interface{} {
+	var value interface{} = marshaler
+	for i := 0; i < maxMarshalerDepth; i++ {
+		marshaler, ok := value.(FieldMarshaler)
+		if !ok {
+			return value
+		}
+		if isNilPointer(marshaler) {
+			return nil
+		}
+		value = marshaler.MarshalField()
+	}
+	return nil
+}
+
+// Encoder converts a field value into the value to write instead, such as a string. It is not called with nil
+// pointers, which are encoded as nil.
+type Encoder func(value interface{}) interface{}
+
+// Creates new Encoders writing time.Duration, net.IP, and url.URL values as strings, such as "1.5s", and []byte
+// values as strings if they are valid UTF-8 or in hexadecimal otherwise.
+func NewEncoders() *Encoders {
+	encoders := &Encoders{
+		types: make(map[reflect.Type]Encoder),
+	}
+	encoders.Register(time.Duration(0), func(value interface{}) interface{} {
+		return value.(time.Duration).String()
+	})
+	encoders.Register(net.IP(nil), func(value interface{}) interface{} {
+		return value.(net.IP).String()
+	})
+	encoders.Register(url.URL{}, func(value interface{}) interface{} {
+		u := value.(url.URL)
+		return u.String()
+	})
+	encoders.Register(&url.URL{}, func(value interface{}) interface{} {
+		return value.(*url.URL).String()
+	})
+	encoders.Register([]byte(nil), func(value interface{}) interface{} {
+		if b := value.([]byte); utf8.Valid(b) {
+			return string(b)
+		}
+		return hex.EncodeToString(value.([]byte))
+	})
+	return encoders
+}
+
+// Encoders is a registry of Encoders by the type of value they encode. A Telemetry's Encoders are applied when its
+// events are marshaled, so that every Formatter writes the encoded values.
+type Encoders struct {
+	types map[reflect.Type]Encoder
+
+	// Encoders of values implementing an interface, in the order they were registered.
+	interfaces []interfaceEncoder
+
+	mutex sync.RWMutex
+}
+
+type interfaceEncoder struct {
+	iface   reflect.Type
+	encoder Encoder
+}
+
+// Registers the encoder of values of the same type as `example`, replacing any previous one. A nil pointer to an
+// interface, such as `(*fmt.Stringer)(nil)`, registers the encoder of values implementing the interface, which is
+// used for values of types without an encoder of their own. Note that time.Time implements fmt.Stringer.
+func (e *Encoders) Register(example interface{}, encoder Encoder) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	t := reflect.TypeOf(example)
+	if t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Interface {
+		e.interfaces = append(e.interfaces, interfaceEncoder{iface: t.Elem(), encoder: encoder})
+		return
+	}
+	e.types[t] = encoder
+}
+
+// Returns the value encoded by the FieldMarshaler it implements or the encoder of its type, if any. Valuers are
+// resolved, and values nested in fields and slices are encoded too, which are copied if anything changed.
+func (e *Encoders) Encode(value interface{}) interface{} {
+	w := walker{visit: e.visit, resolve: true, written: true}
+	encoded, _ := w.walk("", "", value, 0)
+	return encoded
+}
+
+// Replaces the value with the encoder of its type, if any. FieldMarshalers take precedence over encoders.
+func (e *Encoders) visit(path string, key string, value interface{}, depth int) (interface{}, bool, bool) {
+	if _, ok := value.(FieldMarshaler); ok || value == nil || e == nil {
+		return value, false, true
+	}
+	if isNilPointer(value) {
+		return nil, true, false
+	}
+	if encoder, exists := e.lookup(reflect.TypeOf(value)); exists {
+		return encoder(value), true, false
+	}
+	return value, false, true
+}
+
+// Returns the encoder of the type, or of the first interface it implements.
+func (e *Encoders) lookup(t reflect.Type) (Encoder, bool) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if encoder, exists := e.types[t]; exists {
+		return encoder, true
+	}
+	for _, i := range e.interfaces {
+		if t.Implements(i.iface) {
+			return i.encoder, true
+		}
+	}
+	return nil, false
+}
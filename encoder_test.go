@@ -0,0 +1,119 @@
+package telemetry
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type user struct {
+	name     string
+	password string
+}
+
+func (u user) MarshalField() interface{} {
+	return Fields{"name": u.name}
+}
+
+type level int
+
+func (l level) String() string {
+	return [...]string{"low", "high"}[l]
+}
+
+func TestMarshalEncodesValues(t *testing.T) {
+	endpoint, _ := url.Parse("https://example.com/path")
+	_telemetry := New()
+	event := _telemetry.WithFields(Fields{
+		"duration": 1500 * time.Millisecond,
+		"ip":       net.ParseIP("10.0.0.1"),
+		"url":      endpoint,
+		"body":     []byte("hello"),
+		"binary":   []byte{0xff, 0x00},
+		"user":     user{name: "jane", password: "hunter2"},
+		"nested":   Fields{"timeout": time.Second, "levels": []interface{}{level(1)}},
+		"level":    level(0),
+	})
+	fields := event.Marshal()
+	require.Equal(t, "1.5s", fields["duration"])
+	require.Equal(t, "10.0.0.1", fields["ip"])
+	require.Equal(t, "https://example.com/path", fields["url"])
+	require.Equal(t, "hello", fields["body"])
+	require.Equal(t, "ff00", fields["binary"])
+	require.Equal(t, Fields{"name": "jane"}, fields["user"])
+	require.Equal(t, Fields{"timeout": "1s", "levels": []interface{}{level(1)}}, fields["nested"])
+	require.Equal(t, level(0), fields["level"])
+
+	_telemetry.Encoders.Register((*fmt.Stringer)(nil), func(value interface{}) interface{} {
+		return value.(fmt.Stringer).String()
+	})
+	fields = event.Marshal()
+	require.Equal(t, "low", fields["level"])
+	require.Equal(t, Fields{"timeout": "1s", "levels": []interface{}{"high"}}, fields["nested"])
+
+	serialized, err := new(JSONFormatter).Format(map[string]interface{}{"user": user{name: "jane"}})
+	require.NoError(t, err)
+	require.Equal(t, `{"user":{"name":"jane"}}`+"\n", string(serialized))
+}
+
+type account struct {
+	user user
+}
+
+func (a *account) MarshalField() interface{} {
+	return a.user
+}
+
+// Marshals to itself.
+type loop struct{}
+
+func (l loop) MarshalField() interface{} {
+	return l
+}
+
+func TestMarshalNilAndRecursiveFieldMarshalers(t *testing.T) {
+	var missing *account
+	event := New().WithFields(Fields{
+		"account": &account{user: user{name: "jane"}},
+		"missing": missing,
+		"loop":    loop{},
+	})
+	fields := event.Marshal()
+	require.Equal(t, Fields{"name": "jane"}, fields["account"])
+	require.Nil(t, fields["missing"])
+	require.Nil(t, fields["loop"])
+
+	serialized, err := new(JSONFormatter).Format(map[string]interface{}{
+		"account": &account{user: user{name: "jane"}},
+		"missing": missing,
+		"loop":    loop{},
+	})
+	require.NoError(t, err)
+	require.Equal(t, `{"account":{"name":"jane"},"loop":null,"missing":null}`+"\n", string(serialized))
+}
+
+func TestMarshalNilPointersWithEncoders(t *testing.T) {
+	event := New().WithFields(Fields{"url": (*url.URL)(nil), "nested": Fields{"url": (*url.URL)(nil)}})
+	fields := event.Marshal()
+	require.Nil(t, fields["url"])
+	require.Equal(t, Fields{"url": nil}, fields["nested"])
+	require.NotPanics(t, func() {
+		_ = event.Error()
+	})
+}
+
+func TestMarshalEncodesValuesInSlicesOfFields(t *testing.T) {
+	event := New().WithFields(Fields{
+		"steps":    []Fields{{"took": time.Second}, {"user": user{name: "jane"}}},
+		"attempts": []map[string]interface{}{{"took": 2 * time.Second}},
+		"lazy":     []interface{}{ValuerFunc(func() interface{} { return time.Minute })},
+	})
+	fields := event.Marshal()
+	require.Equal(t, []Fields{{"took": "1s"}, {"user": Fields{"name": "jane"}}}, fields["steps"])
+	require.Equal(t, []map[string]interface{}{{"took": "2s"}}, fields["attempts"])
+	require.Equal(t, []interface{}{"1m0s"}, fields["lazy"])
+}
@@ -1,14 +1,34 @@
 package telemetry
 
+import (
+	"strings"
+)
+
 var ErrorKey = "error"
 var ProvenanceKey = "provenance"
 var TimestampKey = "timestamp"
 
 func NewEvent(telemetry *Telemetry) *Event {
-	return &Event{
+	return NewEventSkip(telemetry, 1)
+}
+
+// Creates new Event like NewEvent. If the Telemetry captures the caller or stack trace, `skip` frames above the
+// function calling NewEventSkip are skipped, so that wrappers creating events, such as a logger's methods, can
+// report their own caller.
+func NewEventSkip(telemetry *Telemetry, skip int) *Event {
+	event := &Event{
 		telemetry: telemetry,
-		data:      make(Fields, 5),
 	}
+	if telemetry == nil {
+		return event
+	}
+	if telemetry.CaptureCaller {
+		event = event.WithCaller(skip + 1)
+	}
+	if telemetry.CaptureStacktrace {
+		event = event.WithStacktrace(skip + 1)
+	}
+	return event
 }
 
 // Event maintains track of event data fields and provenance of the event.
@@ -19,17 +39,31 @@ type Event struct {
 	// Contains a list (slice) of provenance of the event.
 	provenance []Fields
 
-	// Contains all non-provenance fields.
-	data Fields
+	// Contains all non-provenance fields, as layers shared with the events this one was derived from.
+	data *layer
 }
 
 func (event *Event) WithField(key string, value interface{}) *Event {
 	return event.WithFields(map[string]interface{}{key: value})
 }
 
-// Adds specified fields to the Event and returns new Event with those fields included.
+// Adds specified fields to the Event and returns new Event with those fields included. Nested Fields are joined as
+// with Join. The Event shares its existing fields with the new Event, so this costs O(len(fields)). Fields whose
+// value is a Valuer are resolved when first read and then keep their value.
 func (event *Event) WithFields(fields Fields) *Event {
-	return &Event{telemetry: event.telemetry, provenance: event.provenance, data: Join(event.data, fields)}
+	if len(fields) == 0 {
+		return &Event{telemetry: event.telemetry, provenance: event.provenance, data: event.data}
+	}
+	return event.derive(mergeLayer, fields)
+}
+
+// Adds specified fields to the Event unless it already has them and returns new Event with those fields included.
+// Nested Fields are joined with the Event's fields taking precedence, as with Join(fields, eventFields).
+func (event *Event) withDefaults(fields Fields) *Event {
+	if len(fields) == 0 {
+		return &Event{telemetry: event.telemetry, provenance: event.provenance, data: event.data}
+	}
+	return event.derive(defaultsLayer, fields)
 }
 
 // Adds specified provenance to the Event and returns new Event with that provenance included.
@@ -48,36 +82,161 @@ func (event *Event) WithProvenance(fields Fields) *Event {
 	return &Event{telemetry: event.telemetry, provenance: provenance, data: event.data}
 }
 
+// Adds the error to the Event under ErrorKey and returns new Event with that error included. The Event wraps the
+// error, so that errors.Is and errors.As find it.
+func (event *Event) WithError(err error) *Event {
+	if err == nil {
+		return event.WithFields(nil) // make a copy
+	}
+	return event.WithField(ErrorKey, err)
+}
+
+// Removes specified fields from the Event and returns new Event without them. Removing ProvenanceKey removes the
+// provenance of the Event.
+func (event *Event) Without(keys ...string) *Event {
+	provenance := event.provenance
+	for _, key := range keys {
+		if key == ProvenanceKey {
+			provenance = nil
+		}
+	}
+	if len(keys) == 0 || event.data == nil {
+		return &Event{telemetry: event.telemetry, provenance: provenance, data: event.data}
+	}
+	return &Event{telemetry: event.telemetry, provenance: provenance, data: newLayer(event.data, deleteLayer, nil, append([]string(nil), keys...))}
+}
+
+// Removes the field at the dotted path, such as "request.headers.authorization", and returns new Event without it.
+// Nested Fields along the path are copied rather than modified, and a key containing dots is matched by its full
+// key first, as with Lookup.
+func (event *Event) WithoutPath(path string) *Event {
+	data, removed := withoutPath(event.data.flatten(), path)
+	if !removed {
+		return event.WithFields(nil) // make a copy
+	}
+	return &Event{telemetry: event.telemetry, provenance: event.provenance, data: newLayer(nil, baseLayer, data, nil)}
+}
+
+// Renames the field at the dotted path `old` to `key`, keeping it at the same depth, and returns new Event with
+// the field renamed. For example, renaming "usage.storage" to "disk" moves the value to "usage.disk". If a field
+// named `key` already exists, nested Fields are joined as with WithFields and other values are replaced.
+func (event *Event) Rename(old string, key string) *Event {
+	data, renamed := renamePath(event.data.flatten(), old, key)
+	if !renamed {
+		return event.WithFields(nil) // make a copy
+	}
+	return &Event{telemetry: event.telemetry, provenance: event.provenance, data: newLayer(nil, baseLayer, data, nil)}
+}
+
+// Returns a copy of the fields with the value at the dotted path removed, copying nested fields along the path.
+func withoutPath(fields map[string]interface{}, path string) (Fields, bool) {
+	return updatePath(fields, path, func(fields Fields, key string) {
+		delete(fields, key)
+	})
+}
+
+// Returns a copy of the fields with the value at the dotted path renamed to key, copying nested fields along the
+// path.
+func renamePath(fields map[string]interface{}, path string, key string) (Fields, bool) {
+	return updatePath(fields, path, func(fields Fields, old string) {
+		value := fields[old]
+		delete(fields, old)
+		if existing, exists := fields[key]; exists {
+			value = joinValues(existing, value)
+		}
+		fields[key] = value
+	})
+}
+
+// Finds the map holding the value at the dotted path and returns a copy of the fields in which `update` was applied
+// to a copy of that map and the final key. Maps along the path are copied and keep their type.
+func updatePath(fields map[string]interface{}, path string, update func(fields Fields, key string)) (Fields, bool) {
+	if _, exists := fields[path]; exists {
+		data := copyFields(fields)
+		update(data, path)
+		return data, true
+	}
+	for dot := strings.IndexByte(path, '.'); dot >= 0; {
+		if nested, ok := asFields(fields[path[:dot]]); ok {
+			if updated, ok := updatePath(nested, path[dot+1:], update); ok {
+				data := copyFields(fields)
+				if _, ok := fields[path[:dot]].(Fields); ok {
+					data[path[:dot]] = updated
+				} else {
+					data[path[:dot]] = map[string]interface{}(updated)
+				}
+				return data, true
+			}
+		}
+		next := strings.IndexByte(path[dot+1:], '.')
+		if next < 0 {
+			break
+		}
+		dot += next + 1
+	}
+	return nil, false
+}
+
+func copyFields(fields map[string]interface{}) Fields {
+	data := make(Fields, len(fields))
+	for k, v := range fields {
+		data[k] = v
+	}
+	return data
+}
+
 // Convert Event into a map[string]interface{} for logging, transport, or other such usage once no further
-// enrichment of the Event is needed.
+// enrichment of the Event is needed. Values are resolved if they are Valuers and encoded with the Encoders of the
+// event's Telemetry.
 func (event *Event) Marshal() map[string]interface{} {
-	fields := make(map[string]interface{}, len(event.data)+1)
+	data := event.data.flatten()
+	var encoders *Encoders
+	if event.telemetry != nil {
+		encoders = event.telemetry.Encoders
+	}
+	fields := make(map[string]interface{}, len(data)+1)
 	if event.provenance != nil {
 		fields[ProvenanceKey] = event.provenance
 	}
-	for k, v := range event.data {
-		fields[k] = v
+	for k, v := range data {
+		fields[k] = encoders.Encode(v)
 	}
 	return fields
 }
 
 // Event implements the Error interface so that structured telemetry can be returned everywhere an error can.
 func (event Event) Error() string {
-	err, exists := event.data[ErrorKey]
-	if !exists {
-		return toString(event)
+	value, _ := event.Get(ErrorKey)
+	switch err := value.(type) {
+	case string:
+		return err
+	case error:
+		return err.Error()
 	}
-	str, ok := err.(string)
-	if !ok {
-		return toString(event)
+	return toString(event)
+}
+
+// Returns the error added with WithError, if any, so that errors.Is and errors.As can inspect the cause of the
+// Event.
+func (event Event) Unwrap() error {
+	value, _ := event.Get(ErrorKey)
+	err, _ := value.(error)
+	return err
+}
+
+// Returns the deprecated TimestampLayout of the event's Telemetry, used for times unless a Formatter has a layout
+// of its own.
+func (event *Event) timestampLayout() string {
+	if event.telemetry == nil {
+		return ""
 	}
-	return str
+	return event.telemetry.TimestampLayout
 }
 
 // Default serializer for use with Error interface implementation.
 func toString(event Event) string {
 	formatter := &JSONFormatter{}
-	str, err := formatter.Format(event.Marshal())
+	str, err := formatter.formatLayout(event.Marshal(), event.timestampLayout())
 	if err != nil {
 		return err.Error()
 	}
@@ -0,0 +1,211 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventFieldAccessors(t *testing.T) {
+	timestamp := time.Date(2017, 2, 18, 22, 2, 35, 452000000, time.UTC)
+	event := New().WithProvenance(Fields{"file": "event_test.go"}).WithFields(Fields{
+		"message":   "hello",
+		"count":     3,
+		"ratio":     0.5,
+		"decoded":   2.0,
+		"enabled":   true,
+		"timestamp": "2017-02-18T22:02:35.452Z",
+		"time":      timestamp,
+		"key2.1":    "dotted",
+		"usage": Fields{
+			"storage": map[string]interface{}{
+				"request": Fields{
+					"value": 2,
+				},
+			},
+			"a.b": Fields{"c": "dotted path"},
+		},
+	})
+
+	value, exists := event.Get("message")
+	require.True(t, exists)
+	require.Equal(t, "hello", value)
+	_, exists = event.Get("usage.storage")
+	require.False(t, exists)
+	provenance, exists := event.Get(ProvenanceKey)
+	require.True(t, exists)
+	require.Equal(t, []Fields{{"file": "event_test.go"}}, provenance)
+
+	str, ok := event.GetString("message")
+	require.True(t, ok)
+	require.Equal(t, "hello", str)
+	_, ok = event.GetString("count")
+	require.False(t, ok)
+
+	i, ok := event.GetInt("usage.storage.request.value")
+	require.True(t, ok)
+	require.Equal(t, int64(2), i)
+	i, ok = event.GetInt("decoded")
+	require.True(t, ok)
+	require.Equal(t, int64(2), i)
+	_, ok = event.GetInt("ratio")
+	require.False(t, ok)
+
+	f, ok := event.GetFloat("ratio")
+	require.True(t, ok)
+	require.Equal(t, 0.5, f)
+	f, ok = event.GetFloat("count")
+	require.True(t, ok)
+	require.Equal(t, 3.0, f)
+
+	b, ok := event.GetBool("enabled")
+	require.True(t, ok)
+	require.True(t, b)
+
+	parsed, ok := event.GetTime("timestamp")
+	require.True(t, ok)
+	require.True(t, timestamp.Equal(parsed))
+	parsed, ok = event.GetTime("time")
+	require.True(t, ok)
+	require.Equal(t, timestamp, parsed)
+
+	fields, ok := event.GetFields("usage.storage")
+	require.True(t, ok)
+	require.Contains(t, fields, "request")
+
+	str, ok = event.GetString("key2.1")
+	require.True(t, ok)
+	require.Equal(t, "dotted", str)
+	str, ok = event.GetString("usage.a.b.c")
+	require.True(t, ok)
+	require.Equal(t, "dotted path", str)
+	_, exists = event.Lookup("usage.missing.value")
+	require.False(t, exists)
+}
+
+func TestEventWithoutAndRename(t *testing.T) {
+	headers := map[string]interface{}{
+		"authorization": "secret",
+		"accept":        "*/*",
+	}
+	base := New().WithProvenance(Fields{"file": "event_test.go"}).WithFields(Fields{
+		"message":  "hello",
+		"password": "secret",
+		"request": Fields{
+			"headers": headers,
+			"user":    Fields{"id": 1},
+			"owner":   Fields{"name": "tristan"},
+		},
+	})
+
+	event := base.Without("password", "missing")
+	_, exists := event.Get("password")
+	require.False(t, exists)
+	require.Equal(t, "hello", event.Marshal()["message"])
+	_, exists = base.Get("password")
+	require.True(t, exists)
+	_, exists = base.Without(ProvenanceKey).Get(ProvenanceKey)
+	require.False(t, exists)
+	keys := []string{"message"}
+	event = base.Without(keys...)
+	keys[0] = "password"
+	_, exists = event.Get("message")
+	require.False(t, exists)
+	_, exists = event.Get("password")
+	require.True(t, exists)
+
+	event = base.WithoutPath("request.headers.authorization")
+	_, exists = event.Lookup("request.headers.authorization")
+	require.False(t, exists)
+	accept, _ := event.GetString("request.headers.accept")
+	require.Equal(t, "*/*", accept)
+	require.Equal(t, "secret", headers["authorization"])
+	_, ok := field(event, "request").(Fields)["headers"].(map[string]interface{})
+	require.True(t, ok)
+	require.Equal(t, base.Marshal(), base.WithoutPath("request.missing").Marshal())
+
+	event = base.Rename("request.user", "owner")
+	_, exists = event.Lookup("request.user")
+	require.False(t, exists)
+	owner, _ := event.GetFields("request.owner")
+	require.Equal(t, Fields{"id": 1, "name": "tristan"}, owner)
+	event = base.Rename("message", "msg")
+	msg, _ := event.GetString("msg")
+	require.Equal(t, "hello", msg)
+	_, exists = event.Get("message")
+	require.False(t, exists)
+}
+
+// Returns the value of the field, or nil if the event does not have it.
+func field(event *Event, key string) interface{} {
+	value, _ := event.Get(key)
+	return value
+}
+
+func TestEventWrapsError(t *testing.T) {
+	cause := fmt.Errorf("opening config: %w", fs.ErrNotExist)
+	var err error = New().WithError(cause).WithField("path", "/etc/app.json")
+	require.Equal(t, "opening config: file does not exist", err.Error())
+	require.True(t, errors.Is(err, fs.ErrNotExist))
+	var event *Event
+	require.True(t, errors.As(err, &event))
+	require.Equal(t, cause, errors.Unwrap(err))
+
+	serialized, formatErr := new(JSONFormatter).Format(event.Marshal())
+	require.NoError(t, formatErr)
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal(serialized, &decoded))
+	require.Equal(t, map[string]interface{}{
+		"message": "opening config: file does not exist",
+		"type":    "*fmt.wrapError",
+		"chain": []interface{}{
+			map[string]interface{}{"message": "file does not exist", "type": "*errors.errorString"},
+		},
+	}, decoded[ErrorKey])
+}
+
+func TestValuersAreResolvedLazilyOnce(t *testing.T) {
+	calls := 0
+	body := ValuerFunc(func() interface{} {
+		calls++
+		return Fields{"size": 42}
+	})
+	event := New().WithField("body", body)
+	emitter := NewEmitter()
+	emitter.AddListener(func(event *Event) {}, MatchField("type", "log"))
+	emitter.Emit(event.WithField("type", "metric"))
+	require.Equal(t, 0, calls)
+
+	var sizes []interface{}
+	for i := 0; i < 2; i++ {
+		emitter.AddListener(func(event *Event) {
+			size, _ := event.Lookup("body.size")
+			sizes = append(sizes, size)
+			require.Equal(t, Fields{"size": 42}, event.Marshal()["body"])
+		})
+	}
+	emitter.Emit(event.WithField("type", "log"))
+	require.Equal(t, []interface{}{42, 42}, sizes)
+	require.Equal(t, 1, calls)
+
+	nestedCalls := 0
+	nested := New().WithField("request", Fields{"timeout": ValuerFunc(func() interface{} {
+		nestedCalls++
+		return time.Second
+	})})
+	for i := 0; i < 2; i++ {
+		require.Equal(t, Fields{"timeout": "1s"}, nested.Marshal()["request"])
+	}
+	require.Equal(t, 1, nestedCalls)
+
+	serialized, err := new(JSONFormatter).Format(map[string]interface{}{
+		"nested": Fields{"lazy": ValuerFunc(func() interface{} { return "value" })},
+	})
+	require.NoError(t, err)
+	require.Equal(t, `{"nested":{"lazy":"value"}}`+"\n", string(serialized))
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+
 	"github.com/tristanls/telemetry"
 )
 
@@ -9,9 +11,8 @@ func main() {
 	var emitter = telemetry.NewEmitter()
 	var writer = telemetry.NewWriter()
 
-	emitter.AddListener(func(event *telemetry.Event) {
-		writer.Write(event.Marshal())
-	})
+	emitter.AddSink(writer)
+	defer emitter.Close(context.Background())
 
 	event := telemetry.New().WithProvenance(telemetry.Fields{
 		"import":  "github.com/tristanls/telemetry",
@@ -11,11 +11,10 @@ func main() {
 	emitter := telemetry.NewEmitter()
 	writer := telemetry.NewWriter()
 
-	emitter.AddListener(func(event *telemetry.Event) {
-		writer.Write(event.WithProvenance(telemetry.Fields{
-			"example": "github.com/tristanls/telemetry/examples/metrics.go",
-		}).Marshal())
-	})
+	emitter.Use(telemetry.AddProvenance(telemetry.Fields{
+		"example": "github.com/tristanls/telemetry/examples/metrics.go",
+	}))
+	emitter.AddSink(writer)
 
 	m := metrics.NewMetrics(_telemetry, emitter)
 
@@ -0,0 +1,163 @@
+package telemetry
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+// Returns the value of the field `key`, resolving it if it is a Valuer. Keys are taken literally, see Lookup for
+// dotted paths. ProvenanceKey returns the provenance of the event, as Marshal does.
+func (event *Event) Get(key string) (interface{}, bool) {
+	value, exists := event.data.get(key)
+	if !exists && key == ProvenanceKey && event.provenance != nil {
+		return event.provenance, true
+	}
+	return resolve(value), exists
+}
+
+// Returns the value at the dotted `path`, such as "usage.storage.request.value", traversing nested Fields and
+// map[string]interface{} values. A field whose key contains dots, such as "key2.1", is found by its full key first.
+func (event *Event) Lookup(path string) (interface{}, bool) {
+	if value, exists := event.Get(path); exists {
+		return value, true
+	}
+	dot := strings.IndexByte(path, '.')
+	if dot < 0 {
+		return nil, false
+	}
+	value, exists := event.Get(path[:dot])
+	if !exists {
+		return nil, false
+	}
+	return lookup(value, path[dot+1:])
+}
+
+// Returns the value at the dotted path within a nested map, trying keys containing dots before splitting on them.
+func lookup(value interface{}, path string) (interface{}, bool) {
+	fields, ok := asFields(value)
+	if !ok {
+		return nil, false
+	}
+	if value, exists := fields[path]; exists {
+		return value, true
+	}
+	for dot := strings.IndexByte(path, '.'); dot >= 0; {
+		if value, exists := fields[path[:dot]]; exists {
+			if value, exists := lookup(value, path[dot+1:]); exists {
+				return value, true
+			}
+		}
+		next := strings.IndexByte(path[dot+1:], '.')
+		if next < 0 {
+			break
+		}
+		dot += next + 1
+	}
+	return nil, false
+}
+
+// Returns the string at the key or dotted path.
+func (event *Event) GetString(path string) (string, bool) {
+	value, _ := event.Lookup(path)
+	str, ok := value.(string)
+	return str, ok
+}
+
+// Returns the integer at the key or dotted path. Any integer type is accepted, as are floating point numbers
+// without a fractional part, such as numbers decoded from JSON.
+func (event *Event) GetInt(path string) (int64, bool) {
+	value, _ := event.Lookup(path)
+	switch value := value.(type) {
+	case int:
+		return int64(value), true
+	case int8:
+		return int64(value), true
+	case int16:
+		return int64(value), true
+	case int32:
+		return int64(value), true
+	case int64:
+		return value, true
+	case uint:
+		return int64(value), value <= math.MaxInt64
+	case uint8:
+		return int64(value), true
+	case uint16:
+		return int64(value), true
+	case uint32:
+		return int64(value), true
+	case uint64:
+		return int64(value), value <= math.MaxInt64
+	case float32:
+		return int64(value), float32(int64(value)) == value
+	case float64:
+		return int64(value), float64(int64(value)) == value
+	}
+	return 0, false
+}
+
+// Returns the number at the key or dotted path as a float64. Any integer or floating point type is accepted.
+func (event *Event) GetFloat(path string) (float64, bool) {
+	value, _ := event.Lookup(path)
+	switch value := value.(type) {
+	case float64:
+		return value, true
+	case float32:
+		return float64(value), true
+	}
+	if i, ok := event.GetInt(path); ok {
+		return float64(i), true
+	}
+	switch value := value.(type) {
+	case uint:
+		return float64(value), true
+	case uint64:
+		return float64(value), true
+	}
+	return 0, false
+}
+
+// Returns the boolean at the key or dotted path.
+func (event *Event) GetBool(path string) (bool, bool) {
+	value, _ := event.Lookup(path)
+	b, ok := value.(bool)
+	return b, ok
+}
+
+// Returns the time at the key or dotted path. Strings are parsed using the TimestampLayout of the event's
+// Telemetry, falling back to RFC 3339.
+func (event *Event) GetTime(path string) (time.Time, bool) {
+	value, _ := event.Lookup(path)
+	switch value := value.(type) {
+	case time.Time:
+		return value, true
+	case string:
+		if event.telemetry != nil {
+			if t, err := time.Parse(event.telemetry.TimestampLayout, value); err == nil {
+				return t, true
+			}
+		}
+		if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Returns the nested Fields at the key or dotted path. Values of type map[string]interface{} are returned as
+// Fields. The returned Fields must not be modified.
+func (event *Event) GetFields(path string) (Fields, bool) {
+	value, _ := event.Lookup(path)
+	return asFields(value)
+}
+
+func asFields(value interface{}) (Fields, bool) {
+	switch value := value.(type) {
+	case Fields:
+		return value, true
+	case map[string]interface{}:
+		return Fields(value), true
+	}
+	return nil, false
+}
@@ -1,6 +1,48 @@
 package telemetry
 
+import (
+	"time"
+)
+
+// Default layout of timestamps, with millisecond precision in UTC.
+const DefaultTimestampLayout = "2006-01-02T15:04:05.000Z"
+
+// Timestamp layouts formatting times as integer numbers of milliseconds or nanoseconds since the Unix epoch. Any
+// other layout, such as time.RFC3339Nano, is used with time.Format.
+const (
+	UnixMilliseconds = "unix_ms"
+	UnixNanoseconds  = "unix_ns"
+)
+
 type Formatter interface {
 	// Format returns a slice of bytes that will be written to `Writer.Out`.
 	Format(map[string]interface{}) ([]byte, error)
 }
+
+// Implemented by Formatters that accept a layout of times to use unless they have one of their own, so that a
+// Writer can pass them the TimestampLayout of the event's Telemetry.
+type layoutFormatter interface {
+	formatLayout(event map[string]interface{}, layout string) ([]byte, error)
+}
+
+// Formats the event with the formatter, passing it the layout if it accepts one.
+func formatWithLayout(formatter Formatter, event map[string]interface{}, layout string) ([]byte, error) {
+	if f, ok := formatter.(layoutFormatter); ok {
+		return f.formatLayout(event, layout)
+	}
+	return formatter.Format(event)
+}
+
+// Returns the time formatted with the layout for use by a Formatter: an int64 for UnixMilliseconds and
+// UnixNanoseconds, or a string in UTC otherwise. Empty layout is DefaultTimestampLayout.
+func FormatTime(t time.Time, layout string) interface{} {
+	switch layout {
+	case UnixMilliseconds:
+		return t.UnixNano() / int64(time.Millisecond)
+	case UnixNanoseconds:
+		return t.UnixNano()
+	case "":
+		layout = DefaultTimestampLayout
+	}
+	return t.UTC().Format(layout)
+}
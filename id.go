@@ -0,0 +1,84 @@
+package telemetry
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var IDKey = "id"
+var SequenceKey = "sequence"
+
+// Last sequence number assigned in this process.
+var sequence uint64
+
+// Returns the next sequence number of this process, starting at 1.
+func nextSequence() uint64 {
+	return atomic.AddUint64(&sequence, 1)
+}
+
+// Crockford's base 32 alphabet used by ULIDs, which sorts the same as the bytes it encodes.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// Generates ULIDs, https://github.com/ulid/spec, that are monotonic within this process: IDs generated within the
+// same millisecond increment the random part of the previous ID instead of drawing a new one, so that they sort in
+// the order they were generated.
+type idGenerator struct {
+	milliseconds uint64
+	entropy      [10]byte
+	mutex        sync.Mutex
+}
+
+var ids idGenerator
+
+// Returns new ULID for an event at the time.
+func newID(t time.Time) string {
+	return ids.generate(t)
+}
+
+func (g *idGenerator) generate(t time.Time) string {
+	milliseconds := uint64(t.UnixNano() / int64(time.Millisecond))
+
+	g.mutex.Lock()
+	if milliseconds > g.milliseconds {
+		g.milliseconds = milliseconds
+		if _, err := rand.Read(g.entropy[:]); err != nil {
+			g.increment() // fall back to a monotonic, if predictable, ID
+		}
+	} else {
+		g.increment() // same millisecond, or the clock went back
+	}
+	var id [16]byte
+	binary.BigEndian.PutUint64(id[:8], g.milliseconds<<16)
+	copy(id[6:], g.entropy[:])
+	g.mutex.Unlock()
+
+	return encodeID(id)
+}
+
+// Increments the random part of the ID, carrying over into the time part once it is exhausted. Must be called with
+// mutex held.
+func (g *idGenerator) increment() {
+	for i := len(g.entropy) - 1; i >= 0; i-- {
+		g.entropy[i]++
+		if g.entropy[i] != 0 {
+			return
+		}
+	}
+	g.milliseconds++
+}
+
+// Encodes the 128 bits of the ID as 26 characters of base 32, the first of which holds only the top 3 bits.
+func encodeID(id [16]byte) string {
+	high := binary.BigEndian.Uint64(id[:8])
+	low := binary.BigEndian.Uint64(id[8:])
+	var encoded [26]byte
+	for i := len(encoded) - 1; i >= 0; i-- {
+		encoded[i] = crockford[low&31]
+		low = low>>5 | high<<59
+		high >>= 5
+	}
+	return string(encoded[:])
+}
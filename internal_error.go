@@ -0,0 +1,60 @@
+package telemetry
+
+import (
+	"fmt"
+	"os"
+)
+
+// InternalError describes a failure inside the telemetry pipeline, such as a Listener or Middleware panicking while
+// handling an event. Emitter reports internal errors to its ErrorHandler instead of letting them reach the emitting code.
+type InternalError struct {
+	// Subscription of the Listener that failed. Nil if it was Middleware that failed.
+	Subscription *Subscription
+
+	// Event being handled when the failure occurred.
+	Event *Event
+
+	// Value the Listener or Middleware panicked with. Nil if Middleware reported Err instead.
+	Value interface{}
+
+	// Error reported with Emitter.Report by Middleware that failed without panicking, such as a validator rejecting
+	// an event.
+	Err error
+
+	// Stack trace of the goroutine at the time of the failure. Nil if Middleware reported Err.
+	Stack []byte
+}
+
+func (e *InternalError) Error() string {
+	switch {
+	case e.Err != nil && e.Subscription == nil:
+		return fmt.Sprintf("middleware failed: %v", e.Err)
+	case e.Err != nil:
+		return fmt.Sprintf("%v failed: %v", e.Subscription, e.Err)
+	case e.Subscription == nil:
+		return fmt.Sprintf("middleware panicked: %v", e.Value)
+	}
+	return fmt.Sprintf("%v panicked: %v", e.Subscription, e.Value)
+}
+
+func (e *InternalError) Unwrap() error {
+	return e.Err
+}
+
+// Writes the internal error to standard error as a log event. It is used when an Emitter has no ErrorHandler.
+func WriteInternalError(internalError *InternalError) {
+	fields := map[string]interface{}{
+		"type":    "log",
+		"level":   "error",
+		"message": internalError.Error(),
+	}
+	if internalError.Stack != nil {
+		fields["stack"] = string(internalError.Stack)
+	}
+	serialized, err := new(JSONFormatter).Format(fields)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "{\"type\":\"log\",\"level\":\"error\",\"message\":\"%v\"}\n", err)
+		return
+	}
+	os.Stderr.Write(serialized)
+}
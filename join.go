@@ -1,24 +1,181 @@
 package telemetry
 
+import (
+	"fmt"
+	"reflect"
+)
+
+// Joins more onto base and returns the result. Nested Fields and map[string]interface{} values are joined, other
+// values in more override those in base.
 func Join(base, more Fields) Fields {
+	data, _ := JoinWith(Override, base, more)
+	return data
+}
+
+// MergeStrategy decides the value of a field present in both Fields being joined, unless both values are nested
+// Fields or map[string]interface{}, which are joined instead. Path is the dotted path of the field, such as
+// "request.headers".
+type MergeStrategy func(path string, existing, more interface{}) (interface{}, error)
+
+// Joins more onto base using the strategy to decide the value of fields present in both and returns the result.
+// Nested Fields and map[string]interface{} values are joined and keep the type of the value in base. Returns the
+// first error returned by the strategy.
+func JoinWith(strategy MergeStrategy, base, more Fields) (Fields, error) {
+	return join(strategy, "", base, more)
+}
+
+func join(strategy MergeStrategy, prefix string, base, more Fields) (Fields, error) {
 	data := make(Fields, len(base)+len(more))
 	for k, v := range base {
 		data[k] = v
 	}
 	for k, v := range more {
-		if existing, exists := data[k]; exists {
+		existing, exists := data[k]
+		if !exists {
+			data[k] = v
+			continue
+		}
+		value, err := merge(strategy, prefix+k, existing, v)
+		if err != nil {
+			return nil, err
+		}
+		data[k] = value
+	}
+	return data, nil
+}
+
+// Returns the result of merging more onto existing, joining nested fields and deferring to the strategy otherwise.
+func merge(strategy MergeStrategy, path string, existing, more interface{}) (interface{}, error) {
+	if existingFields, ok := asFields(existing); ok {
+		if moreFields, ok := asFields(more); ok {
+			joined, err := join(strategy, path+".", existingFields, moreFields)
+			if err != nil {
+				return nil, err
+			}
 			if _, ok := existing.(Fields); ok {
-				if _, ok := v.(Fields); ok {
-					data[k] = Join(existing.(Fields), v.(Fields))
-				} else {
-					data[k] = v // override if not joining Fields
-				}
-			} else {
-				data[k] = v // override if not joining Fields
+				return joined, nil
 			}
-		} else {
-			data[k] = v
+			return map[string]interface{}(joined), nil
 		}
 	}
-	return data
-}
\ No newline at end of file
+	return strategy(path, existing, more)
+}
+
+// Returns the result of joining more onto existing: nested fields are joined, other values are overridden.
+func joinValues(existing, more interface{}) interface{} {
+	value, _ := merge(Override, "", existing, more)
+	return value
+}
+
+// Override is a MergeStrategy keeping the later value. It is the strategy of Join.
+func Override(path string, existing, more interface{}) (interface{}, error) {
+	return more, nil
+}
+
+// KeepFirst is a MergeStrategy keeping the earlier value.
+func KeepFirst(path string, existing, more interface{}) (interface{}, error) {
+	return existing, nil
+}
+
+// AppendSlices is a MergeStrategy appending the later slice to the earlier one. Slices of different types are
+// appended into an []interface{}. Other values are overridden.
+func AppendSlices(path string, existing, more interface{}) (interface{}, error) {
+	a, b := reflect.ValueOf(existing), reflect.ValueOf(more)
+	if a.Kind() != reflect.Slice || b.Kind() != reflect.Slice {
+		return more, nil
+	}
+	sliceType := a.Type()
+	if b.Type() != sliceType {
+		sliceType = reflect.TypeOf([]interface{}{})
+	}
+	result := reflect.MakeSlice(sliceType, 0, a.Len()+b.Len())
+	for _, slice := range []reflect.Value{a, b} {
+		for i := 0; i < slice.Len(); i++ {
+			result = reflect.Append(result, slice.Index(i))
+		}
+	}
+	return result.Interface(), nil
+}
+
+// SumNumbers is a MergeStrategy adding the later number to the earlier one. Numbers of the same type add up to
+// that type; otherwise, integers add up to an int64 and other numbers to a float64. Other values are overridden.
+func SumNumbers(path string, existing, more interface{}) (interface{}, error) {
+	a, b := reflect.ValueOf(existing), reflect.ValueOf(more)
+	if !isNumber(a) || !isNumber(b) {
+		return more, nil
+	}
+	if a.Type() == b.Type() {
+		sum := reflect.New(a.Type()).Elem()
+		switch {
+		case isInt(a):
+			sum.SetInt(a.Int() + b.Int())
+		case isUint(a):
+			sum.SetUint(a.Uint() + b.Uint())
+		default:
+			sum.SetFloat(a.Float() + b.Float())
+		}
+		return sum.Interface(), nil
+	}
+	if (isInt(a) || isUint(a)) && (isInt(b) || isUint(b)) {
+		return toInt64(a) + toInt64(b), nil
+	}
+	return toFloat64(a) + toFloat64(b), nil
+}
+
+// ErrorOnConflict is a MergeStrategy returning a *ConflictError unless both values are equal.
+func ErrorOnConflict(path string, existing, more interface{}) (interface{}, error) {
+	if !equal(existing, more) {
+		return nil, &ConflictError{Path: path, Existing: existing, More: more}
+	}
+	return existing, nil
+}
+
+// ConflictError describes a field with different values in the Fields being joined.
+type ConflictError struct {
+	// Dotted path of the field.
+	Path string
+
+	Existing interface{}
+	More     interface{}
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("join: conflicting values of %q: %v and %v", e.Path, e.Existing, e.More)
+}
+
+func isNumber(value reflect.Value) bool {
+	return isInt(value) || isUint(value) || value.Kind() == reflect.Float32 || value.Kind() == reflect.Float64
+}
+
+func isInt(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
+
+func isUint(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
+
+func toInt64(value reflect.Value) int64 {
+	if isUint(value) {
+		return int64(value.Uint())
+	}
+	return value.Int()
+}
+
+func toFloat64(value reflect.Value) float64 {
+	switch {
+	case isInt(value):
+		return float64(value.Int())
+	case isUint(value):
+		return float64(value.Uint())
+	}
+	return value.Float()
+}
@@ -41,3 +41,70 @@ func TestJoinFieldsWithFields(t *testing.T) {
 	require.Equal(t, "value3.1.2", result["key3"].(Fields)["key3.1"].(Fields)["key3.1.2"])
 	require.Equal(t, "type_mismatch_override", result["key4"])
 }
+
+func TestJoinMapsWithFields(t *testing.T) {
+	base := Fields{
+		"config": map[string]interface{}{
+			"host": "localhost",
+			"port": 80.0,
+		},
+	}
+	more := Fields{
+		"config": map[string]interface{}{
+			"port": 8080.0,
+		},
+	}
+	result := Join(base, more)
+	require.Equal(t, map[string]interface{}{"host": "localhost", "port": 8080.0}, result["config"])
+	result = Join(base, Fields{"config": Fields{"tls": true}})
+	require.Equal(t, map[string]interface{}{"host": "localhost", "port": 80.0, "tls": true}, result["config"])
+}
+
+func TestJoinWithStrategies(t *testing.T) {
+	base := Fields{
+		"tags":  []string{"a"},
+		"count": 1,
+		"name":  "first",
+		"nested": Fields{
+			"bytes": 10.5,
+		},
+	}
+	more := Fields{
+		"tags":  []string{"b", "c"},
+		"count": 2,
+		"name":  "second",
+		"nested": map[string]interface{}{
+			"bytes": 1,
+		},
+	}
+
+	result, err := JoinWith(AppendSlices, base, more)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b", "c"}, result["tags"])
+	require.Equal(t, 2, result["count"])
+	require.Equal(t, "second", result["name"])
+
+	result, err = JoinWith(KeepFirst, base, more)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a"}, result["tags"])
+	require.Equal(t, "first", result["name"])
+	require.Equal(t, Fields{"bytes": 10.5}, result["nested"])
+
+	result, err = JoinWith(SumNumbers, base, more)
+	require.NoError(t, err)
+	require.Equal(t, 3, result["count"])
+	require.Equal(t, Fields{"bytes": 11.5}, result["nested"])
+	require.Equal(t, "second", result["name"])
+
+	_, err = JoinWith(ErrorOnConflict, base, Fields{"name": "first", "nested": Fields{"bytes": 1}})
+	require.Equal(t, &ConflictError{Path: "nested.bytes", Existing: 10.5, More: 1}, err)
+	result, err = JoinWith(ErrorOnConflict, base, Fields{"name": "first", "tags": []string{"a"}})
+	require.NoError(t, err)
+	require.Equal(t, "first", result["name"])
+}
+
+func TestAppendSlicesOfDifferentTypes(t *testing.T) {
+	result, err := AppendSlices("tags", []string{"a"}, []interface{}{1})
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{"a", 1}, result)
+}
@@ -2,25 +2,77 @@ package telemetry
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
 )
 
-type JSONFormatter struct{}
+type JSONFormatter struct {
+	// Layout of time.Time values, such as timestamps, formatted with FormatTime. If empty, Writer.Listen and
+	// Writer.ListenBatch use the TimestampLayout of the event's Telemetry, and other callers, including Writer.Write,
+	// DefaultTimestampLayout.
+	TimestampLayout string
+}
 
 func (f *JSONFormatter) Format(event map[string]interface{}) ([]byte, error) {
-	data := make(map[string]interface{}, len(event))
-	for k, v := range event {
-		switch v := v.(type) {
-		case error:
-			// Copied from logrus implementation because errors are ignored by `encoding/json`
-			// as explained in https://github.com/Sirupsen/logrus/issues/137
-			data[k] = v.Error()
-		default:
-			data[k] = v
-		}
+	return f.formatLayout(event, "")
+}
+
+// Formats the event like Format, using the layout for times unless the JSONFormatter has a TimestampLayout.
+func (f *JSONFormatter) formatLayout(event map[string]interface{}, layout string) ([]byte, error) {
+	if f.TimestampLayout != "" {
+		layout = f.TimestampLayout
 	}
-	serialized, err := json.Marshal(data)
+	serialized, err := json.Marshal(f.format(event, layout))
 	if err != nil {
 		return nil, err
 	}
 	return append(serialized, '\n'), nil
 }
+
+// Returns a copy of the fields, and of nested fields, with values that `encoding/json` can't encode as intended
+// formatted. Times are formatted with the layout.
+func (f *JSONFormatter) format(fields map[string]interface{}, layout string) map[string]interface{} {
+	data := make(map[string]interface{}, len(fields))
+	for k, v := range fields {
+		data[k] = f.formatValue(v, layout)
+	}
+	return data
+}
+
+func (f *JSONFormatter) formatValue(value interface{}, layout string) interface{} {
+	switch value := resolve(value).(type) {
+	case FieldMarshaler:
+		return f.formatValue(marshalField(value), layout)
+	case error:
+		// Errors are ignored by `encoding/json` as explained in https://github.com/Sirupsen/logrus/issues/137
+		return formatError(value)
+	case time.Time:
+		return FormatTime(value, layout)
+	case Fields:
+		return f.format(value, layout)
+	case map[string]interface{}:
+		return f.format(value, layout)
+	default:
+		return value
+	}
+}
+
+// Describes the error by its message and type, along with the chain of errors it wraps, if any.
+func formatError(err error) map[string]interface{} {
+	var chain []map[string]interface{}
+	for cause := errors.Unwrap(err); cause != nil; cause = errors.Unwrap(cause) {
+		chain = append(chain, map[string]interface{}{
+			"message": cause.Error(),
+			"type":    fmt.Sprintf("%T", cause),
+		})
+	}
+	formatted := map[string]interface{}{
+		"message": err.Error(),
+		"type":    fmt.Sprintf("%T", err),
+	}
+	if chain != nil {
+		formatted["chain"] = chain
+	}
+	return formatted
+}
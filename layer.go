@@ -0,0 +1,220 @@
+package telemetry
+
+import (
+	"sync/atomic"
+)
+
+// Maximum number of layers above the nearest base layer. Deriving an event beyond it flattens the fields into a
+// new base layer, which bounds the cost of reading a field.
+const maxLayerDepth = 32
+
+// Maximum number of fields a merge or defaults layer keeps in a slice allocated together with the layer and its
+// event instead of in a map. Events are usually derived with a few fields at a time.
+const inlineFields = 4
+
+// What a layer does to the fields of the layers below it.
+type layerKind uint8
+
+const (
+	// Fields replace the fields below, which are ignored.
+	baseLayer layerKind = iota
+	// Fields are joined onto the fields below, as with Join.
+	mergeLayer
+	// Fields below take precedence over the fields, as with Join(fields, below).
+	defaultsLayer
+	// Keys are removed from the fields below.
+	deleteLayer
+)
+
+// Layer is an immutable set of changes to the fields of an event, applied on top of a parent layer. Deriving an
+// event adds a layer sharing the layers of the event it was derived from, so it costs O(added fields) instead of
+// copying every field. Layers are flattened into a single map only when all fields are needed, as by Marshal.
+type layer struct {
+	parent *layer
+	kind   layerKind
+
+	// Fields added by a base layer, or by a merge or defaults layer with more than inlineFields fields.
+	fields Fields
+
+	// Fields added by a merge or defaults layer with at most inlineFields fields.
+	entries []entry
+
+	// Keys removed by a delete layer.
+	keys []string
+
+	// Number of layers above the nearest base layer.
+	depth int
+
+	// Flattened fields of this layer and the layers below, stored as Fields once computed.
+	flattened atomic.Value
+}
+
+// Field of a layer kept in a slice.
+type entry struct {
+	key   string
+	value interface{}
+}
+
+// Event allocated together with its layer and the layer's fields, so that deriving an event with a few fields
+// costs a single allocation.
+type derivedEvent struct {
+	event   Event
+	layer   layer
+	entries [inlineFields]entry
+}
+
+// Returns a layer with the changes on top of parent, or a base layer with all fields once the parent is too deep.
+func newLayer(parent *layer, kind layerKind, fields Fields, keys []string) *layer {
+	l := &layer{parent: parent, kind: kind, fields: fields, keys: keys}
+	if kind == baseLayer {
+		l.parent = nil
+		l.flattened.Store(fields)
+		return l
+	}
+	return l.stack()
+}
+
+// Returns new Event with a merge or defaults layer of a copy of the fields on top of the event's layers. Valuers
+// among the fields are replaced with cached ones.
+func (event *Event) derive(kind layerKind, fields Fields) *Event {
+	derived := &derivedEvent{event: Event{telemetry: event.telemetry, provenance: event.provenance}}
+	l := &derived.layer
+	l.parent, l.kind = event.data, kind
+	if len(fields) <= inlineFields {
+		l.entries = derived.entries[:0]
+		for k, v := range fields {
+			l.entries = append(l.entries, entry{key: k, value: cacheValuer(v)})
+		}
+	} else {
+		l.fields = copyFields(fields)
+		cacheValuers(l.fields)
+	}
+	derived.event.data = l.stack()
+	return &derived.event
+}
+
+// Places the layer on top of its parent, returning a base layer with all fields instead once the parent is too
+// deep.
+func (l *layer) stack() *layer {
+	if l.parent != nil {
+		l.depth = l.parent.depth + 1
+	}
+	if l.depth > maxLayerDepth {
+		return newLayer(nil, baseLayer, l.flatten(), nil)
+	}
+	return l
+}
+
+// Returns the field of a merge or defaults layer with the key.
+func (l *layer) field(key string) (interface{}, bool) {
+	if l.entries == nil {
+		value, exists := l.fields[key]
+		return value, exists
+	}
+	for _, e := range l.entries {
+		if e.key == key {
+			return e.value, true
+		}
+	}
+	return nil, false
+}
+
+// Calls `f` with each field of a merge or defaults layer.
+func (l *layer) each(f func(key string, value interface{})) {
+	for k, v := range l.fields {
+		f(k, v)
+	}
+	for _, e := range l.entries {
+		f(e.key, e.value)
+	}
+}
+
+// Returns the value of the field with the key, joining nested fields across layers as necessary.
+func (l *layer) get(key string) (interface{}, bool) {
+	if l == nil {
+		return nil, false
+	}
+	if flattened, ok := l.flattened.Load().(Fields); ok {
+		value, exists := flattened[key]
+		return value, exists
+	}
+	switch l.kind {
+	case deleteLayer:
+		for _, k := range l.keys {
+			if k == key {
+				return nil, false
+			}
+		}
+		return l.parent.get(key)
+	case defaultsLayer:
+		value, exists := l.parent.get(key)
+		defaultValue, hasDefault := l.field(key)
+		switch {
+		case !hasDefault:
+			return value, exists
+		case !exists:
+			return defaultValue, true
+		}
+		return joinValues(defaultValue, value), true
+	}
+	value, exists := l.field(key)
+	if !exists {
+		return l.parent.get(key)
+	}
+	if _, ok := asFields(value); ok {
+		if below, exists := l.parent.get(key); exists {
+			return joinValues(below, value), true
+		}
+	}
+	return value, true
+}
+
+// Returns all fields of this layer and the layers below. The returned Fields must not be modified.
+func (l *layer) flatten() Fields {
+	if l == nil {
+		return nil
+	}
+	if flattened, ok := l.flattened.Load().(Fields); ok {
+		return flattened
+	}
+	var layers []*layer
+	below := l
+	for ; below != nil; below = below.parent {
+		if _, ok := below.flattened.Load().(Fields); ok {
+			break
+		}
+		layers = append(layers, below)
+	}
+	fields := copyFields(below.flatten())
+	for i := len(layers) - 1; i >= 0; i-- {
+		layers[i].apply(fields)
+	}
+	l.flattened.Store(fields)
+	return fields
+}
+
+// Applies the changes of the layer to the fields in place.
+func (l *layer) apply(fields Fields) {
+	switch l.kind {
+	case mergeLayer:
+		l.each(func(k string, v interface{}) {
+			if existing, exists := fields[k]; exists {
+				fields[k] = joinValues(existing, v)
+			} else {
+				fields[k] = v
+			}
+		})
+	case defaultsLayer:
+		l.each(func(k string, v interface{}) {
+			if existing, exists := fields[k]; exists {
+				fields[k] = joinValues(v, existing)
+			} else {
+				fields[k] = v
+			}
+		})
+	case deleteLayer:
+		for _, k := range l.keys {
+			delete(fields, k)
+		}
+	}
+}
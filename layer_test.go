@@ -0,0 +1,97 @@
+package telemetry
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLayersReadLikeFlattenedFields(t *testing.T) {
+	event := New().WithFields(Fields{
+		"a":      1,
+		"nested": Fields{"x": 1, "y": Fields{"z": 1}},
+		"gone":   true,
+	})
+	events := []*Event{event}
+	event = event.WithFields(Fields{"nested": Fields{"y": Fields{"w": 2}}, "b": 2})
+	events = append(events, event)
+	event = event.Without("gone", "a")
+	events = append(events, event)
+	event = event.withDefaults(Fields{"a": "default", "b": "ignored", "nested": Fields{"x": "ignored", "v": 3}})
+	events = append(events, event)
+	event = event.WithFields(Fields{"nested": "replaced"})
+	events = append(events, event)
+	event = event.WithFields(Fields{"nested": Fields{"again": true}})
+	events = append(events, event)
+
+	for i, event := range events {
+		flattened := event.Marshal()
+		for _, key := range []string{"a", "b", "nested", "gone", "missing"} {
+			value, exists := event.Get(key)
+			expected, expectedExists := flattened[key]
+			require.Equal(t, expectedExists, exists, "event %d key %s", i, key)
+			require.Equal(t, expected, value, "event %d key %s", i, key)
+		}
+	}
+	require.Equal(t, map[string]interface{}{
+		"a":      1,
+		"b":      2,
+		"nested": Fields{"x": 1, "y": Fields{"z": 1, "w": 2}},
+		"gone":   true,
+	}, events[1].Marshal())
+	require.Equal(t, map[string]interface{}{
+		"a":      "default",
+		"b":      2,
+		"nested": Fields{"x": 1, "y": Fields{"z": 1, "w": 2}, "v": 3},
+	}, events[3].Marshal())
+	require.Equal(t, Fields{"again": true}, events[5].Marshal()["nested"])
+}
+
+func TestDeepLayersAreFlattened(t *testing.T) {
+	event := NewEvent(New())
+	for i := 0; i < 3*maxLayerDepth; i++ {
+		event = event.WithField(fmt.Sprint(i), i)
+		require.True(t, event.data.depth <= maxLayerDepth)
+	}
+	require.Len(t, event.Marshal(), 3*maxLayerDepth)
+	value, _ := event.Get("0")
+	require.Equal(t, 0, value)
+}
+
+// Fields of a base event and the fields added to it in each derivation step.
+func derivationFields() (Fields, []Fields) {
+	base := make(Fields, 50)
+	for i := 0; i < 50; i++ {
+		base[fmt.Sprint("base", i)] = i
+	}
+	steps := make([]Fields, 10)
+	for i := range steps {
+		steps[i] = Fields{fmt.Sprint("step", i): i, "level": "info"}
+	}
+	return base, steps
+}
+
+func BenchmarkDeriveEvent(b *testing.B) {
+	base, steps := derivationFields()
+	event := New().WithFields(base)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		derived := event
+		for _, step := range steps {
+			derived = derived.WithFields(step)
+		}
+		derived.Marshal()
+	}
+}
+
+func BenchmarkWithFields(b *testing.B) {
+	base, steps := derivationFields()
+	event := New().WithFields(base)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		event.WithFields(steps[0])
+	}
+}
@@ -0,0 +1,206 @@
+package telemetry
+
+import (
+	"fmt"
+	"sort"
+	"unicode/utf8"
+)
+
+// Key of the field listing what Limits truncated in an event, such as "body: string of 52428800 bytes".
+var TruncatedKey = "truncated"
+
+// Creates new Limits cutting strings to 32 KiB, nested fields to a depth of 10, fields to 1000 keys, provenance to 32
+// entries, and events to 1 MiB once formatted.
+func NewLimits() *Limits {
+	return &Limits{
+		MaxStringLength: 32 << 10,
+		MaxDepth:        10,
+		MaxKeys:         1000,
+		MaxProvenance:   32,
+		MaxBytes:        1 << 20,
+		Keep:            []string{TimestampKey, "type", "level", "message", ErrorKey, IDKey},
+	}
+}
+
+// Limits bounds the size of events on their way out, so that a single accidental log of a huge payload doesn't
+// overwhelm a log shipper. Truncation is deterministic: fields are considered in order of their keys, and a
+// TruncatedKey field lists what was truncated. Zero disables a limit.
+type Limits struct {
+	// Maximum length of a string value in bytes. Longer strings are cut at the last character that fits.
+	MaxStringLength int
+
+	// Maximum depth of nested fields, where fields of the event are at depth 1. Nested fields beyond it are
+	// replaced with null.
+	MaxDepth int
+
+	// Maximum number of keys of the event or of nested fields. The first keys in sorted order are kept, after the
+	// keys in Keep for the event.
+	MaxKeys int
+
+	// Maximum number of provenance entries. The first entries are kept.
+	MaxProvenance int
+
+	// Maximum size of the formatted event in bytes. The largest fields not in Keep are dropped until the event
+	// fits; if it still doesn't, formatting fails.
+	MaxBytes int
+
+	// Keys of fields of the event kept when there are too many keys or bytes.
+	Keep []string
+}
+
+// Returns a Formatter limiting events before formatting them with `formatter`.
+func (l *Limits) Formatter(formatter Formatter) Formatter {
+	return &limitingFormatter{limits: l, formatter: formatter}
+}
+
+type limitingFormatter struct {
+	limits    *Limits
+	formatter Formatter
+}
+
+func (f *limitingFormatter) Format(event map[string]interface{}) ([]byte, error) {
+	return f.formatLayout(event, "")
+}
+
+func (f *limitingFormatter) formatLayout(event map[string]interface{}, layout string) ([]byte, error) {
+	var truncated []string
+	w := walker{visit: f.limits.visitor(&truncated), resolve: true, paths: true}
+	limited, changed := w.walk("", "", event, 0)
+	fields := Fields(limited.(map[string]interface{}))
+	if changed {
+		fields[TruncatedKey] = marker(truncated)
+	}
+	serialized, err := formatWithLayout(f.formatter, fields, layout)
+	if err != nil || f.limits.MaxBytes <= 0 || len(serialized) <= f.limits.MaxBytes {
+		return serialized, err
+	}
+	if !changed {
+		fields = copyFields(fields)
+	}
+	for _, key := range f.limits.droppable(fields) {
+		delete(fields, key)
+		truncated = append(truncated, fmt.Sprintf("%s: dropped to fit %d bytes", key, f.limits.MaxBytes))
+		fields[TruncatedKey] = marker(truncated)
+		serialized, err = formatWithLayout(f.formatter, fields, layout)
+		if err != nil || len(serialized) <= f.limits.MaxBytes {
+			return serialized, err
+		}
+	}
+	return nil, fmt.Errorf("event of %d bytes exceeds limit of %d bytes", len(serialized), f.limits.MaxBytes)
+}
+
+// Returns what was truncated, sorted.
+func marker(truncated []string) []string {
+	sorted := append([]string(nil), truncated...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+// Returns the keys of the fields that may be dropped, largest first and in sorted order among equal sizes.
+func (l *Limits) droppable(fields Fields) []string {
+	var keys []string
+	sizes := make(map[string]int, len(fields))
+	for k, v := range fields {
+		if k != TruncatedKey && !l.keep(k) {
+			keys = append(keys, k)
+			sizes[k] = estimateSize(v)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if sizes[keys[i]] != sizes[keys[j]] {
+			return sizes[keys[i]] > sizes[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+func (l *Limits) keep(key string) bool {
+	for _, k := range l.Keep {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns a visitor limiting values and appending what it truncated. Errors are limited in the form JSONFormatter
+// writes them, which replaces the error only if it was truncated.
+func (l *Limits) visitor(truncated *[]string) visitor {
+	return func(path string, key string, value interface{}, depth int) (interface{}, bool, bool) {
+		switch value := value.(type) {
+		case string:
+			limited, changed := l.limitString(path, value, truncated)
+			return limited, changed, false
+		case error:
+			return formatError(value), false, true
+		case Fields:
+			if l.beyondDepth(path, depth, truncated) {
+				return nil, true, false
+			}
+			limited, changed := l.limitKeys(path, value, depth, truncated)
+			return limited, changed, true
+		case map[string]interface{}:
+			if l.beyondDepth(path, depth, truncated) {
+				return nil, true, false
+			}
+			limited, changed := l.limitKeys(path, value, depth, truncated)
+			return map[string]interface{}(limited), changed, true
+		case []Fields:
+			if key == ProvenanceKey && depth == 1 && l.MaxProvenance > 0 && len(value) > l.MaxProvenance {
+				*truncated = append(*truncated, fmt.Sprintf("%s: %d entries", ProvenanceKey, len(value)))
+				return value[:l.MaxProvenance], true, true
+			}
+		}
+		return value, false, true
+	}
+}
+
+// Returns whether nested fields at the depth are beyond MaxDepth, appending that they were truncated if so.
+func (l *Limits) beyondDepth(path string, depth int, truncated *[]string) bool {
+	if l.MaxDepth <= 0 || depth < l.MaxDepth {
+		return false
+	}
+	*truncated = append(*truncated, fmt.Sprintf("%s: nested beyond depth %d", path, l.MaxDepth))
+	return true
+}
+
+// Returns the fields at the depth with at most MaxKeys keys and whether any were left out. The first keys in sorted
+// order are kept, after the keys in Keep for the event.
+func (l *Limits) limitKeys(path string, fields map[string]interface{}, depth int, truncated *[]string) (Fields, bool) {
+	if l.MaxKeys <= 0 || len(fields) <= l.MaxKeys {
+		return fields, false
+	}
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if depth == 0 {
+		sort.SliceStable(keys, func(i, j int) bool {
+			return l.keep(keys[i]) && !l.keep(keys[j])
+		})
+	}
+	limited := make(Fields, l.MaxKeys)
+	for _, k := range keys[:l.MaxKeys] {
+		limited[k] = fields[k]
+	}
+	name := path
+	if name == "" {
+		name = "event"
+	}
+	*truncated = append(*truncated, fmt.Sprintf("%s: %d keys", name, len(keys)))
+	return limited, true
+}
+
+func (l *Limits) limitString(path string, value string, truncated *[]string) (interface{}, bool) {
+	if l.MaxStringLength <= 0 || len(value) <= l.MaxStringLength {
+		return value, false
+	}
+	*truncated = append(*truncated, fmt.Sprintf("%s: string of %d bytes", path, len(value)))
+	end := l.MaxStringLength
+	for end > 0 && !utf8.RuneStart(value[end]) {
+		end--
+	}
+	return value[:end], true
+}
@@ -0,0 +1,107 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimitsTruncateEvents(t *testing.T) {
+	limits := &Limits{
+		MaxStringLength: 8,
+		MaxDepth:        2,
+		MaxKeys:         3,
+		MaxProvenance:   1,
+		Keep:            []string{"type"},
+	}
+	event := map[string]interface{}{
+		"type":        "log",
+		"message":     "héllo wörld",
+		"a":           Fields{"b": Fields{"c": 1}, "d": []string{"short", "much too long"}},
+		"z":           1,
+		ProvenanceKey: []Fields{{"module": "app"}, {"module": "db"}},
+	}
+	serialized, err := limits.Formatter(new(JSONFormatter)).Format(event)
+	require.NoError(t, err)
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal(serialized, &decoded))
+	require.Equal(t, map[string]interface{}{
+		"type": "log",
+		"a": map[string]interface{}{
+			"b": nil,
+			"d": []interface{}{"short", "much too"},
+		},
+		"message": "héllo w",
+		TruncatedKey: []interface{}{
+			"a.b: nested beyond depth 2",
+			"a.d[1]: string of 13 bytes",
+			"event: 5 keys",
+			"message: string of 13 bytes",
+		},
+	}, decoded)
+	require.Equal(t, "héllo wörld", event["message"])
+
+	serialized, err = limits.Formatter(new(JSONFormatter)).Format(map[string]interface{}{
+		ProvenanceKey: []Fields{{"module": "app"}, {"module": "db"}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, `{"provenance":[{"module":"app"}],"truncated":["provenance: 2 entries"]}`+"\n", string(serialized))
+}
+
+func TestLimitsDropLargestFieldsToFitBytes(t *testing.T) {
+	limits := &Limits{MaxBytes: 160, Keep: []string{"message"}}
+	formatter := limits.Formatter(new(JSONFormatter))
+	fields := map[string]interface{}{
+		"message": strings.Repeat("m", 40),
+		"body":    strings.Repeat("b", 100),
+		"headers": strings.Repeat("h", 50),
+		"status":  200,
+	}
+	serialized, err := formatter.Format(fields)
+	require.NoError(t, err)
+	require.Equal(t, `{"message":"`+strings.Repeat("m", 40)+`","status":200,"truncated":["body: dropped to fit 160 bytes","headers: dropped to fit 160 bytes"]}`+"\n", string(serialized))
+	require.Len(t, fields, 4)
+
+	fields["message"] = strings.Repeat("m", 200)
+	_, err = formatter.Format(fields)
+	require.Error(t, err)
+}
+
+func TestLimitsTruncateErrorsAndValuers(t *testing.T) {
+	limits := &Limits{MaxStringLength: 20, Keep: []string{ErrorKey}}
+	cause := errors.New(strings.Repeat("c", 30))
+	event := map[string]interface{}{
+		ErrorKey: fmt.Errorf("%s: %w", strings.Repeat("e", 30), cause),
+		"nested": Fields{"body": ValuerFunc(func() interface{} {
+			return strings.Repeat("b", 30)
+		})},
+		"user": user{name: strings.Repeat("j", 30)},
+		"ok":   errors.New("short"),
+	}
+	serialized, err := limits.Formatter(new(JSONFormatter)).Format(event)
+	require.NoError(t, err)
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal(serialized, &decoded))
+	require.Equal(t, map[string]interface{}{
+		ErrorKey: map[string]interface{}{
+			"message": strings.Repeat("e", 20),
+			"type":    "*fmt.wrapError",
+			"chain": []interface{}{
+				map[string]interface{}{"message": strings.Repeat("c", 20), "type": "*errors.errorString"},
+			},
+		},
+		"nested": map[string]interface{}{"body": strings.Repeat("b", 20)},
+		"user":   map[string]interface{}{"name": strings.Repeat("j", 20)},
+		"ok":     map[string]interface{}{"message": "short", "type": "*errors.errorString"},
+		TruncatedKey: []interface{}{
+			"error.chain[0].message: string of 30 bytes",
+			"error.message: string of 62 bytes",
+			"nested.body: string of 30 bytes",
+			"user.name: string of 30 bytes",
+		},
+	}, decoded)
+}
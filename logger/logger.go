@@ -1,9 +1,9 @@
 package logger
 
 import (
+	"errors"
 	"fmt"
 	"strings"
-	"sync"
 	"github.com/tristanls/telemetry"
 )
 
@@ -44,6 +44,9 @@ func (level Level) String() string {
 	return "unknown"
 }
 
+// Error wrapped by the error ParseLevel returns for an unknown level.
+var ErrInvalidLevel = errors.New("Expected log level to be one of: debug, info, warn, error, fatal.")
+
 func ParseLevel(level string) (Level, error) {
 	switch strings.ToLower(level) {
 	case "debug":
@@ -59,31 +62,53 @@ func ParseLevel(level string) (Level, error) {
 	}
 
 	var l Level
-	return l, telemetry.New().WithFields(telemetry.Fields{
-		"error": "Expected log level to be one of: debug, info, warn, error, fatal.",
-		"args":  []string{level},
-	})
+	return l, telemetry.New().WithError(ErrInvalidLevel).WithField("args", []string{level})
+}
+
+// Returns a Matcher that matches log events at the specified level or a more severe one, for example
+// `MatchLevel(Warn)` matches warn, error, and fatal events.
+func MatchLevel(level Level) telemetry.Matcher {
+	return telemetry.MatchAll(
+		telemetry.MatchField("type", "log"),
+		telemetry.MatchFieldFunc("level", func(value interface{}) bool {
+			str, ok := value.(string)
+			if !ok {
+				return false
+			}
+			l, err := ParseLevel(str)
+			return err == nil && l <= level
+		}),
+	)
 }
 
 // Creates new Logger instance that will emit Events on provided telemetry emitter using provided telemetry
 // configuration.
 func NewLogger(telemetry *telemetry.Telemetry, emitter *telemetry.Emitter) *Logger {
 	return &Logger{
-		telemetry: telemetry,
-		emitter:   emitter,
-		level:     Info,
+		telemetry:    telemetry,
+		emitter:      emitter,
+		level:        Info,
+		CaptureLevel: Error,
 	}
 }
 
 func NewLoggerWithLevel(telemetry *telemetry.Telemetry, emitter *telemetry.Emitter, level Level) *Logger {
 	return &Logger{
-		telemetry: telemetry,
-		emitter:   emitter,
-		level:     level,
+		telemetry:    telemetry,
+		emitter:      emitter,
+		level:        level,
+		CaptureLevel: Error,
 	}
 }
 
 type Logger struct {
+	// Add the caller and stack trace to log events at CaptureLevel or a more severe level. The caller is the code
+	// calling the Logger, not the Logger's own methods.
+	Capture bool
+
+	// Least severe level at which the caller and stack trace are added if Capture is set. Default is Error.
+	CaptureLevel Level
+
 	telemetry *telemetry.Telemetry
 
 	// Telemetry emitter on which to emit log events.
@@ -91,157 +116,142 @@ type Logger struct {
 
 	// Logger's log level
 	level Level
+}
 
-	// Reusable empty event pool.
-	eventPool sync.Pool
+func (logger *Logger) Log(level Level, args ...interface{}) {
+	logger.log(level, nil, args)
 }
 
-func (logger *Logger) newEvent() *telemetry.Event {
-	event, ok := logger.eventPool.Get().(*telemetry.Event)
-	if ok {
-		return event
-	}
-	return telemetry.NewEvent(logger.telemetry)
+func (logger *Logger) Logf(level Level, format string, args ...interface{}) {
+	logger.logf(level, nil, format, args)
 }
 
-func (logger *Logger) releaseEvent(event *telemetry.Event) {
-	logger.eventPool.Put(event)
+func (logger *Logger) Loge(level Level, event *telemetry.Event, args ...interface{}) {
+	logger.log(level, event, args)
 }
 
-func (logger *Logger) Log(level Level, args ...interface{}) {
+func (logger *Logger) Logef(level Level, event *telemetry.Event, format string, args ...interface{}) {
+	logger.logf(level, event, format, args)
+}
+
+// Every exported logging method calls log or logf directly, so that the caller is always the same number of frames
+// above emit.
+func (logger *Logger) log(level Level, event *telemetry.Event, args []interface{}) {
 	if logger.level >= level {
-		event := logger.newEvent()
-		defer logger.releaseEvent(event)
 		if len(args) > 0 {
-			logger.emitter.Emit(event.WithFields(telemetry.Fields{
+			logger.emit(level, event, telemetry.Fields{
 				"type":    "log",
 				"level":   level.String(),
 				"message": fmt.Sprint(args...),
-			}))
+			})
 		} else {
-			logger.emitter.Emit(event.WithFields(telemetry.Fields{
+			logger.emit(level, event, telemetry.Fields{
 				"type":  "log",
 				"level": level.String(),
-			}))
+			})
 		}
 	}
 }
 
-func (logger *Logger) Logf(level Level, format string, args ...interface{}) {
+func (logger *Logger) logf(level Level, event *telemetry.Event, format string, args []interface{}) {
 	if logger.level >= level {
-		event := logger.newEvent()
-		defer logger.releaseEvent(event)
-		logger.emitter.Emit(event.WithFields(telemetry.Fields{
+		logger.emit(level, event, telemetry.Fields{
 			"type":    "log",
 			"level":   level.String(),
 			"message": fmt.Sprintf(format, args...),
-		}))
+		})
 	}
 }
 
-func (logger *Logger) Loge(level Level, event *telemetry.Event, args ...interface{}) {
-	if logger.level >= level {
-		if len(args) > 0 {
-			logger.emitter.Emit(event.WithFields(telemetry.Fields{
-				"type":    "log",
-				"level":   level.String(),
-				"message": fmt.Sprint(args...),
-			}))
-		} else {
-			logger.emitter.Emit(event.WithFields(telemetry.Fields{
-				"type":  "log",
-				"level": level.String(),
-			}))
-		}
+// Emits the event, or new event if nil, with the fields. Caller and stack trace skip emit, log or logf, and the
+// exported logging method.
+func (logger *Logger) emit(level Level, event *telemetry.Event, fields telemetry.Fields) {
+	const skip = 3
+	if event == nil {
+		event = telemetry.NewEventSkip(logger.telemetry, skip)
 	}
-}
-
-func (logger *Logger) Logef(level Level, event *telemetry.Event, format string, args ...interface{}) {
-	if logger.level >= level {
-		logger.emitter.Emit(event.WithFields(telemetry.Fields{
-			"type":    "log",
-			"level":   level.String(),
-			"message": fmt.Sprintf(format, args...),
-		}))
+	if logger.Capture && level <= logger.CaptureLevel {
+		event = event.WithCaller(skip).WithStacktrace(skip)
 	}
+	logger.emitter.Emit(event.WithFields(fields))
 }
 
 func (logger *Logger) Debug(args ...interface{}) {
-	logger.Log(Debug, args...)
+	logger.log(Debug, nil, args)
 }
 
 func (logger *Logger) Debugf(format string, args ...interface{}) {
-	logger.Logf(Debug, format, args...)
+	logger.logf(Debug, nil, format, args)
 }
 
 func (logger *Logger) Debuge(event *telemetry.Event, args ...interface{}) {
-	logger.Loge(Debug, event, args...)
+	logger.log(Debug, event, args)
 }
 
 func (logger *Logger) Debugef(event *telemetry.Event, format string, args ...interface{}) {
-	logger.Logef(Debug, event, format, args...)
+	logger.logf(Debug, event, format, args)
 }
 
 func (logger *Logger) Info(args ...interface{}) {
-	logger.Log(Info, args...)
+	logger.log(Info, nil, args)
 }
 
 func (logger *Logger) Infof(format string, args ...interface{}) {
-	logger.Logf(Info, format, args...)
+	logger.logf(Info, nil, format, args)
 }
 
 func (logger *Logger) Infoe(event *telemetry.Event, args ...interface{}) {
-	logger.Loge(Info, event, args...)
+	logger.log(Info, event, args)
 }
 
 func (logger *Logger) Infoef(event *telemetry.Event, format string, args ...interface{}) {
-	logger.Logef(Info, event, format, args...)
+	logger.logf(Info, event, format, args)
 }
 
 func (logger *Logger) Warn(args ...interface{}) {
-	logger.Log(Warn, args...)
+	logger.log(Warn, nil, args)
 }
 
 func (logger *Logger) Warnf(format string, args ...interface{}) {
-	logger.Logf(Warn, format, args...)
+	logger.logf(Warn, nil, format, args)
 }
 
 func (logger *Logger) Warne(event *telemetry.Event, args ...interface{}) {
-	logger.Loge(Warn, event, args...)
+	logger.log(Warn, event, args)
 }
 
 func (logger *Logger) Warnef(event *telemetry.Event, format string, args ...interface{}) {
-	logger.Logef(Warn, event, format, args...)
+	logger.logf(Warn, event, format, args)
 }
 
 func (logger *Logger) Error(args ...interface{}) {
-	logger.Log(Error, args...)
+	logger.log(Error, nil, args)
 }
 
 func (logger *Logger) Errorf(format string, args ...interface{}) {
-	logger.Logf(Error, format, args...)
+	logger.logf(Error, nil, format, args)
 }
 
 func (logger *Logger) Errore(event *telemetry.Event, args ...interface{}) {
-	logger.Loge(Error, event, args...)
+	logger.log(Error, event, args)
 }
 
 func (logger *Logger) Erroref(event *telemetry.Event, format string, args ...interface{}) {
-	logger.Logef(Error, event, format, args...)
+	logger.logf(Error, event, format, args)
 }
 
 func (logger *Logger) Fatal(args ...interface{}) {
-	logger.Log(Fatal, args...)
+	logger.log(Fatal, nil, args)
 }
 
 func (logger *Logger) Fatalf(format string, args ...interface{}) {
-	logger.Logf(Fatal, format, args...)
+	logger.logf(Fatal, nil, format, args)
 }
 
 func (logger *Logger) Fatale(event *telemetry.Event, args ...interface{}) {
-	logger.Loge(Fatal, event, args...)
+	logger.log(Fatal, event, args)
 }
 
 func (logger *Logger) Fatalef(event *telemetry.Event, format string, args ...interface{}) {
-	logger.Logef(Fatal, event, format, args...)
+	logger.logf(Fatal, event, format, args)
 }
@@ -0,0 +1,29 @@
+package logger
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tristanls/telemetry"
+)
+
+func TestLoggerReportsCallerOfLoggingMethod(t *testing.T) {
+	_telemetry := telemetry.New()
+	emitter := telemetry.NewEmitter()
+	var functions []string
+	emitter.AddListener(func(event *telemetry.Event) {
+		function, _ := event.GetString(telemetry.CallerKey + ".function")
+		functions = append(functions, function)
+	})
+	log := NewLogger(_telemetry, emitter)
+	log.Capture = true
+
+	log.Errorf("failed %d times", 2)
+	log.Erroref(_telemetry.WithField("attempt", 2), "failed %d times", 2)
+	log.Logf(Error, "failed %d times", 2)
+	_telemetry.CaptureCaller = true
+	log.Info("hello")
+
+	const caller = "github.com/tristanls/telemetry/logger.TestLoggerReportsCallerOfLoggingMethod"
+	require.Equal(t, []string{caller, caller, caller, caller}, functions)
+}
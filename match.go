@@ -0,0 +1,77 @@
+package telemetry
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Matcher decides whether an event is delivered to a Subscription. Matchers are provided when adding a Listener or
+// a Sink to an Emitter, so that, for example, metric and log events can be routed to different Writers.
+type Matcher func(event *Event) bool
+
+// Returns a Matcher that matches events where the field `key` is equal to `value`. The key can be a dotted path
+// into nested Fields, as with Event.Lookup.
+func MatchField(key string, value interface{}) Matcher {
+	return MatchFieldFunc(key, func(v interface{}) bool {
+		return equal(v, value)
+	})
+}
+
+// Returns a Matcher that matches events where the field `key`, or dotted path, is present and its value satisfies
+// `match`.
+func MatchFieldFunc(key string, match func(value interface{}) bool) Matcher {
+	return func(event *Event) bool {
+		value, exists := event.Lookup(key)
+		return exists && match(value)
+	}
+}
+
+// Returns a Matcher that matches events where the field `key` is a string starting with `prefix`.
+func MatchPrefix(key string, prefix string) Matcher {
+	return MatchFieldFunc(key, func(value interface{}) bool {
+		str, ok := value.(string)
+		return ok && strings.HasPrefix(str, prefix)
+	})
+}
+
+// Returns a Matcher that matches events matched by every one of `matchers`.
+func MatchAll(matchers ...Matcher) Matcher {
+	return func(event *Event) bool {
+		for _, match := range matchers {
+			if !match(event) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Returns a Matcher that matches events matched by at least one of `matchers`.
+func MatchAny(matchers ...Matcher) Matcher {
+	return func(event *Event) bool {
+		for _, match := range matchers {
+			if match(event) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Returns a Matcher that matches events not matched by `matcher`.
+func MatchNot(matcher Matcher) Matcher {
+	return func(event *Event) bool {
+		return !matcher(event)
+	}
+}
+
+// Compares field values without panicking on values that are not comparable, such as Fields or slices.
+func equal(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if !reflect.TypeOf(a).Comparable() || !reflect.TypeOf(b).Comparable() {
+		return reflect.DeepEqual(a, b)
+	}
+	return a == b
+}
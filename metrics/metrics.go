@@ -1,11 +1,21 @@
 package metrics
 
 import (
+	"fmt"
 	"sync"
+	"time"
 
 	"github.com/tristanls/telemetry"
 )
 
+// Matches metric events.
+var Match = telemetry.MatchField("type", "metric")
+
+// Returns a Matcher that matches metric events of the specified target type, such as "counter" or "timer".
+func MatchTargetType(targetType string) telemetry.Matcher {
+	return telemetry.MatchAll(Match, telemetry.MatchField("target_type", targetType))
+}
+
 // Creates a new Metrics instance that will emit Events on provided telemetry emitter using provided telemetry
 // configuration.
 func NewMetrics(telemetry *telemetry.Telemetry, emitter *telemetry.Emitter) *Metrics {
@@ -20,21 +30,6 @@ type Metrics struct {
 
 	// Telemetry emitter on which to emit metric events.
 	emitter *telemetry.Emitter
-
-	// Reusable empty event pool.
-	eventPool sync.Pool
-}
-
-func (m *Metrics) newEvent() *telemetry.Event {
-	event, ok := m.eventPool.Get().(*telemetry.Event)
-	if ok {
-		return event
-	}
-	return telemetry.NewEvent(m.telemetry)
-}
-
-func (m *Metrics) releaseEvent(event *telemetry.Event) {
-	m.eventPool.Put(event)
 }
 
 func (m *Metrics) Metric(event *telemetry.Event) {
@@ -82,3 +77,81 @@ func (m *Metrics) Timer(name string, event *telemetry.Event) {
 		"target_type": "timer",
 	}))
 }
+
+// Emits delivery statistics of the emitter as metric events every interval, so that the telemetry pipeline can
+// monitor itself. Counts are emitted as counters with cumulative values and handling time percentiles as gauges in
+// milliseconds, with events about a subscription carrying its id and name. Reporting stops when the returned
+// function is called. Panics if interval is not positive, as time.NewTicker does, in the calling goroutine rather
+// than the reporting one.
+func (m *Metrics) ReportStats(emitter *telemetry.Emitter, interval time.Duration) (stop func()) {
+	if interval <= 0 {
+		panic(fmt.Sprintf("metrics: non-positive interval %v for ReportStats", interval))
+	}
+	done := make(chan struct{})
+	var once sync.Once
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.EmitStats(emitter.Stats())
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		once.Do(func() {
+			close(done)
+		})
+	}
+}
+
+// Emits the emitter delivery statistics as metric events.
+func (m *Metrics) EmitStats(stats telemetry.EmitterStats) {
+	m.Counter("telemetry.emitter.emitted", m.telemetry.WithFields(telemetry.Fields{
+		"unit":  "Event",
+		"value": stats.Emitted,
+	}))
+	m.Counter("telemetry.emitter.dropped", m.telemetry.WithFields(telemetry.Fields{
+		"unit":  "Event",
+		"value": stats.Dropped,
+	}))
+	m.Gauge("telemetry.emitter.queued", m.telemetry.WithFields(telemetry.Fields{
+		"unit":  "Event",
+		"value": stats.Queued,
+	}))
+	for _, subscription := range stats.Subscriptions {
+		event := m.telemetry.WithFields(telemetry.Fields{
+			"subscription": telemetry.Fields{
+				"id":   subscription.ID,
+				"name": subscription.Name,
+			},
+		})
+		m.Counter("telemetry.subscription.delivered", event.WithFields(telemetry.Fields{
+			"unit":  "Event",
+			"value": subscription.Delivered,
+		}))
+		m.Counter("telemetry.subscription.dropped", event.WithFields(telemetry.Fields{
+			"unit":  "Event",
+			"value": subscription.Dropped,
+		}))
+		m.Counter("telemetry.subscription.panicked", event.WithFields(telemetry.Fields{
+			"unit":  "Event",
+			"value": subscription.Panicked,
+		}))
+		m.Gauge("telemetry.subscription.latency", event.WithFields(telemetry.Fields{
+			"unit": "ms",
+			"value": telemetry.Fields{
+				"median":       milliseconds(subscription.P50),
+				"percentile90": milliseconds(subscription.P90),
+				"percentile99": milliseconds(subscription.P99),
+			},
+		}))
+	}
+}
+
+func milliseconds(duration time.Duration) float64 {
+	return float64(duration) / float64(time.Millisecond)
+}
@@ -0,0 +1,66 @@
+package telemetry
+
+import (
+	"context"
+)
+
+// Middleware processes events on their way from Emit to listeners. It may transform or enrich the event and pass
+// the result to `next`, drop the event by not calling `next`, or split it by calling `next` more than once.
+type Middleware interface {
+	Handle(event *Event, next Listener)
+}
+
+// MiddlewareFunc adapts an ordinary function to the Middleware interface.
+type MiddlewareFunc func(event *Event, next Listener)
+
+func (f MiddlewareFunc) Handle(event *Event, next Listener) {
+	f(event, next)
+}
+
+// Returns a Listener that passes events through middleware in order before handing them to `listener`.
+func Chain(middleware []Middleware, listener Listener) Listener {
+	next := listener
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler, n := middleware[i], next
+		next = func(event *Event) {
+			handler.Handle(event, n)
+		}
+	}
+	return next
+}
+
+// Flushes middleware implementing the Flusher interface, returning the first error.
+func flushMiddleware(ctx context.Context, middleware []Middleware) error {
+	var err error
+	for _, m := range middleware {
+		if flusher, ok := m.(Flusher); ok {
+			if flushErr := flusher.Flush(ctx); flushErr != nil && err == nil {
+				err = flushErr
+			}
+		}
+	}
+	return err
+}
+
+// Returns a Middleware that adds the fields to every event.
+func AddFields(fields Fields) Middleware {
+	return MiddlewareFunc(func(event *Event, next Listener) {
+		next(event.WithFields(fields))
+	})
+}
+
+// Returns a Middleware that adds the provenance to every event.
+func AddProvenance(fields Fields) Middleware {
+	return MiddlewareFunc(func(event *Event, next Listener) {
+		next(event.WithProvenance(fields))
+	})
+}
+
+// Returns a Middleware that drops events not matched by the matcher.
+func Filter(matcher Matcher) Middleware {
+	return MiddlewareFunc(func(event *Event, next Listener) {
+		if matcher(event) {
+			next(event)
+		}
+	})
+}
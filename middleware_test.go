@@ -0,0 +1,60 @@
+package telemetry
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChainAppliesMiddlewareInOrder(t *testing.T) {
+	var received []*Event
+	listener := Chain([]Middleware{
+		AddFields(Fields{"first": 1, "order": "first"}),
+		AddFields(Fields{"order": "second"}),
+		AddProvenance(Fields{"host": "example"}),
+	}, func(event *Event) {
+		received = append(received, event)
+	})
+	listener(New().WithField("message", "hello"))
+	require.Len(t, received, 1)
+	fields := received[0].Marshal()
+	require.Equal(t, "hello", fields["message"])
+	require.Equal(t, 1, fields["first"])
+	require.Equal(t, "second", fields["order"])
+	require.Equal(t, []Fields{{"host": "example"}}, fields[ProvenanceKey])
+}
+
+func TestMiddlewareCanDropAndSplitEvents(t *testing.T) {
+	emitter := NewEmitter()
+	emitter.Use(
+		Filter(MatchNot(MatchField("level", "debug"))),
+		MiddlewareFunc(func(event *Event, next Listener) {
+			next(event.WithField("copy", 1))
+			next(event.WithField("copy", 2))
+		}),
+	)
+	var copies []interface{}
+	emitter.AddListener(func(event *Event) {
+		copies = append(copies, field(event, "copy"))
+	})
+	emitter.Emit(New().WithField("level", "debug"))
+	emitter.Emit(New().WithField("level", "info"))
+	require.Equal(t, []interface{}{1, 2}, copies)
+}
+
+func TestPanickingMiddlewareIsReported(t *testing.T) {
+	emitter := NewEmitter()
+	var reported *InternalError
+	emitter.ErrorHandler = func(err *InternalError) {
+		reported = err
+	}
+	emitter.Use(MiddlewareFunc(func(event *Event, next Listener) {
+		panic("oops")
+	}))
+	require.NotPanics(t, func() {
+		emitter.Emit(New().WithField("i", 0))
+	})
+	require.NotNil(t, reported)
+	require.Nil(t, reported.Subscription)
+	require.Equal(t, "middleware panicked: oops", reported.Error())
+}
@@ -1,6 +1,8 @@
 package pod
 
 import (
+	"context"
+
 	"github.com/tristanls/telemetry"
 	"github.com/tristanls/telemetry/logger"
 	"github.com/tristanls/telemetry/metrics"
@@ -23,3 +25,20 @@ func New(telemetry *telemetry.Telemetry, emitter *telemetry.Emitter, log *logger
 		Metrics:   metrics,
 	}
 }
+
+// Flushes the Pod's Emitter, delivering pending events and flushing its sinks. Gives up when the context is done.
+func (content *Content) Flush(ctx context.Context) error {
+	if content.Emitter == nil {
+		return nil
+	}
+	return content.Emitter.Flush(ctx)
+}
+
+// Closes the Pod's Emitter, delivering pending events and closing its sinks. Call it before the process exits so
+// that the last events are not lost. Gives up when the context is done.
+func (content *Content) Close(ctx context.Context) error {
+	if content.Emitter == nil {
+		return nil
+	}
+	return content.Emitter.Close(ctx)
+}
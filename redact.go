@@ -0,0 +1,175 @@
+package telemetry
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Patterns of common secrets in string values.
+var (
+	CreditCardPattern = regexp.MustCompile(`\b(?:\d{4}[ -]?){3}\d{1,4}\b`)
+	EmailPattern      = regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`)
+	JWTPattern        = regexp.MustCompile(`\beyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+)
+
+// Redactable is implemented by values that know which of their parts are secret, such as a type holding
+// credentials. A Redactor replaces them with the value returned by Redact.
+type Redactable interface {
+	Redact() interface{}
+}
+
+// RedactMode decides what a Redactor replaces secrets with.
+type RedactMode int
+
+const (
+	// Replace secrets with "[REDACTED]".
+	RedactMask RedactMode = iota
+	// Replace all but the last few characters of secrets with asterisks, such as "************1111".
+	RedactPartial
+	// Replace secrets with a keyed hash, such as "hmac:5d41402abc4b2a76", so that equal secrets can still be
+	// correlated without being revealed.
+	RedactHash
+)
+
+// Creates new Redactor replacing secrets according to `mode`. It redacts fields named "password",
+// "authorization", or "token", and credit card numbers, email addresses, and JWTs in string values.
+func NewRedactor(mode RedactMode) *Redactor {
+	hashKey := make([]byte, 32)
+	rand.Read(hashKey)
+	return &Redactor{
+		Keys:     []string{"password", "authorization", "token"},
+		Patterns: []*regexp.Regexp{CreditCardPattern, EmailPattern, JWTPattern},
+		Mode:     mode,
+		HashKey:  hashKey,
+		Visible:  4,
+	}
+}
+
+// Redactor replaces secrets in events before they leave the process. It redacts the whole value of fields whose
+// key is one of Keys, at any depth of nested fields, the parts of string values matching one of Patterns, and
+// values implementing Redactable. It is a Middleware, and can also redact events as part of a Formatter.
+type Redactor struct {
+	// Keys of fields whose values are secrets, matched regardless of case.
+	Keys []string
+
+	// Patterns of secrets in string values.
+	Patterns []*regexp.Regexp
+
+	// What secrets are replaced with.
+	Mode RedactMode
+
+	// Key of the HMAC used in RedactHash mode. Default is a random key, so hashes can only be correlated within
+	// the process; set a shared key to correlate them across processes.
+	HashKey []byte
+
+	// Number of characters left visible at the end of secrets in RedactPartial mode. Secrets not longer than
+	// twice as many characters are masked completely.
+	Visible int
+}
+
+// Handle passes the event with its fields and provenance redacted to `next`. It implements the Middleware interface.
+func (r *Redactor) Handle(event *Event, next Listener) {
+	var changes Fields
+	for k, v := range event.data.flatten() {
+		if redacted, changed := r.redactField(k, v); changed {
+			if changes == nil {
+				changes = make(Fields)
+			}
+			changes[k] = redacted
+		}
+	}
+	provenance, redacted := r.redactField(ProvenanceKey, event.provenance)
+	if changes == nil && !redacted {
+		next(event)
+		return
+	}
+	if changes != nil {
+		event = event.WithFields(changes)
+	}
+	redactedProvenance, _ := provenance.([]Fields)
+	next(&Event{telemetry: event.telemetry, provenance: redactedProvenance, data: event.data})
+}
+
+// Returns a Formatter redacting events before formatting them with `formatter`, so that secrets are redacted only
+// on their way out, such as for a Writer to a third party, while other Sinks receive them as emitted.
+func (r *Redactor) Formatter(formatter Formatter) Formatter {
+	return &redactingFormatter{redactor: r, formatter: formatter}
+}
+
+type redactingFormatter struct {
+	redactor  *Redactor
+	formatter Formatter
+}
+
+func (f *redactingFormatter) Format(event map[string]interface{}) ([]byte, error) {
+	return f.formatLayout(event, "")
+}
+
+func (f *redactingFormatter) formatLayout(event map[string]interface{}, layout string) ([]byte, error) {
+	w := walker{visit: f.redactor.visit, resolve: true}
+	redacted, _ := w.walkFields("", event, 0)
+	return formatWithLayout(f.formatter, redacted, layout)
+}
+
+// Returns the value of the field of the event with the key redacted and whether it changed.
+func (r *Redactor) redactField(key string, value interface{}) (interface{}, bool) {
+	w := walker{visit: r.visit, resolve: true}
+	return w.walk(key, key, value, 1)
+}
+
+// Replaces the value of a field with a secret key, Redactable values, and secrets in strings and error messages.
+func (r *Redactor) visit(path string, key string, value interface{}, depth int) (interface{}, bool, bool) {
+	for _, k := range r.Keys {
+		if key != "" && strings.EqualFold(k, key) {
+			if value == nil {
+				return nil, false, false
+			}
+			return r.replace(fmt.Sprint(value)), true, false
+		}
+	}
+	switch value := value.(type) {
+	case Redactable:
+		return value.Redact(), true, false
+	case string:
+		redacted, changed := r.redactString(value)
+		return redacted, changed, false
+	case error:
+		redacted, changed := r.redactString(value.Error())
+		if changed {
+			return redacted, true, false
+		}
+		return value, false, false
+	}
+	return value, false, true
+}
+
+// Returns the string with the parts matching Patterns replaced and whether it changed.
+func (r *Redactor) redactString(value string) (interface{}, bool) {
+	redacted := value
+	for _, pattern := range r.Patterns {
+		redacted = pattern.ReplaceAllStringFunc(redacted, r.replace)
+	}
+	return redacted, redacted != value
+}
+
+// Returns the replacement of the secret according to Mode.
+func (r *Redactor) replace(secret string) string {
+	switch r.Mode {
+	case RedactPartial:
+		characters := []rune(secret)
+		if len(characters) <= 2*r.Visible {
+			return strings.Repeat("*", len(characters))
+		}
+		return strings.Repeat("*", len(characters)-r.Visible) + string(characters[len(characters)-r.Visible:])
+	case RedactHash:
+		mac := hmac.New(sha256.New, r.HashKey)
+		mac.Write([]byte(secret))
+		return "hmac:" + hex.EncodeToString(mac.Sum(nil)[:8])
+	}
+	return "[REDACTED]"
+}
@@ -0,0 +1,371 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Key of the field recording how many events a sampled event represents, so that downstream aggregation can
+// re-weight it. For example, an event kept at a sample rate of 10 stands for itself and 9 dropped events.
+var SampleRateKey = "sample_rate"
+
+// Sampler decides which events are kept.
+type Sampler interface {
+	// Sample returns whether to keep the event and, if so, how many events like it the event represents.
+	Sample(event *Event) (keep bool, rate float64)
+}
+
+// SamplerFunc adapts an ordinary function to the Sampler interface.
+type SamplerFunc func(event *Event) (keep bool, rate float64)
+
+func (f SamplerFunc) Sample(event *Event) (bool, float64) {
+	return f(event)
+}
+
+// Returns a Middleware that drops events not kept by the sampler and records the sample rate of kept events in
+// the SampleRateKey field. It can be used with an Emitter or with a single Subscription.
+func Sample(sampler Sampler) Middleware {
+	return MiddlewareFunc(func(event *Event, next Listener) {
+		keep, rate := sampler.Sample(event)
+		if !keep {
+			return
+		}
+		next(withSampleRate(event, rate))
+	})
+}
+
+// Records the sample rate in the event, combining it with the rate of any earlier sampling.
+func withSampleRate(event *Event, rate float64) *Event {
+	if rate <= 1 {
+		return event
+	}
+	existing, _ := event.Get(SampleRateKey)
+	switch existing := existing.(type) {
+	case float64:
+		rate *= existing
+	case int:
+		rate *= float64(existing)
+	}
+	return event.WithField(SampleRateKey, rate)
+}
+
+// Returns a function that identifies events by the values of the specified fields, for use as a sampler key.
+func FieldKey(keys ...string) func(*Event) string {
+	return func(event *Event) string {
+		values := make([]string, len(keys))
+		for i, key := range keys {
+			if value, exists := event.Get(key); exists {
+				values[i] = fmt.Sprint(value)
+			}
+		}
+		return strings.Join(values, "\x00")
+	}
+}
+
+// Creates new FixedRateSampler that keeps one in `rate` events.
+func NewFixedRateSampler(rate float64) *FixedRateSampler {
+	return &FixedRateSampler{
+		Rate:   rate,
+		random: rand.Float64,
+	}
+}
+
+// FixedRateSampler keeps each event with a probability of 1/Rate.
+type FixedRateSampler struct {
+	// Number of events each kept event represents. Rate of 1 or less keeps every event.
+	Rate float64
+
+	// Returns a random number in [0, 1).
+	random func() float64
+}
+
+func (s *FixedRateSampler) Sample(event *Event) (bool, float64) {
+	if s.Rate <= 1 {
+		return true, 1
+	}
+	return s.random() < 1/s.Rate, s.Rate
+}
+
+// Creates new TokenBucketSampler that keeps, for each key, up to `rate` events per second with bursts of up to
+// `burst` events. Events with the same key share a bucket; a nil `key` puts all events in one bucket.
+func NewTokenBucketSampler(key func(*Event) string, rate float64, burst int) *TokenBucketSampler {
+	return &TokenBucketSampler{
+		Key:     key,
+		Rate:    rate,
+		Burst:   burst,
+		now:     time.Now,
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// TokenBucketSampler keeps events while their key's bucket has tokens. A kept event represents itself and the
+// events with the same key dropped since the previous kept one.
+type TokenBucketSampler struct {
+	// Returns the bucket key of an event. Nil puts all events in one bucket.
+	Key func(*Event) string
+
+	// Tokens added to each bucket per second.
+	Rate float64
+
+	// Capacity of each bucket.
+	Burst int
+
+	now func() time.Time
+
+	buckets map[string]*tokenBucket
+
+	// Time buckets were last checked for being full.
+	pruned time.Time
+
+	mutex sync.Mutex
+}
+
+type tokenBucket struct {
+	tokens  float64
+	last    time.Time
+	dropped int
+}
+
+// Number of buckets above which full buckets are discarded, since they behave like new ones. They are looked for
+// at most once in the time it takes to fill an empty bucket, by which every bucket unused since the previous look
+// is full.
+const maxTokenBuckets = 1 << 14
+
+func (s *TokenBucketSampler) Sample(event *Event) (bool, float64) {
+	var key string
+	if s.Key != nil {
+		key = s.Key(event)
+	}
+	now := s.now()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	refilled := s.Rate > 0 && now.Sub(s.pruned).Seconds()*s.Rate >= float64(s.Burst)
+	if len(s.buckets) > maxTokenBuckets && refilled {
+		for k, b := range s.buckets {
+			if s.refill(b, now) >= float64(s.Burst) {
+				delete(s.buckets, k)
+			}
+		}
+		s.pruned = now
+	}
+	bucket, exists := s.buckets[key]
+	if !exists {
+		bucket = &tokenBucket{tokens: float64(s.Burst), last: now}
+		s.buckets[key] = bucket
+	}
+	bucket.tokens = s.refill(bucket, now)
+	bucket.last = now
+	if bucket.tokens < 1 {
+		bucket.dropped++
+		return false, 0
+	}
+	bucket.tokens--
+	rate := float64(bucket.dropped + 1)
+	bucket.dropped = 0
+	return true, rate
+}
+
+// Returns the tokens in the bucket at the given time.
+func (s *TokenBucketSampler) refill(bucket *tokenBucket, now time.Time) float64 {
+	return math.Min(float64(s.Burst), bucket.tokens+now.Sub(bucket.last).Seconds()*s.Rate)
+}
+
+// Creates new BurstSampler that, for each distinct level and message, keeps the first `first` events in every
+// `interval` and every `thereafter`th event after that.
+func NewBurstSampler(first int, thereafter int, interval time.Duration) *BurstSampler {
+	return &BurstSampler{
+		Key:        FieldKey("level", "message"),
+		First:      first,
+		Thereafter: thereafter,
+		Interval:   interval,
+		now:        time.Now,
+		counters:   make(map[string]*burstCounter),
+	}
+}
+
+// BurstSampler keeps the first First events with the same key in every Interval and then every Thereafter-th one.
+type BurstSampler struct {
+	// Returns the key of an event. Default identifies events by their level and message.
+	Key func(*Event) string
+
+	// Number of events with the same key kept in each interval before sampling starts.
+	First int
+
+	// Keep every Thereafter-th event once First events were kept. Zero drops all of them.
+	Thereafter int
+
+	// Length of the interval after which counting starts over.
+	Interval time.Duration
+
+	now func() time.Time
+
+	counters map[string]*burstCounter
+
+	// Time counters were last checked for expiry.
+	pruned time.Time
+
+	mutex sync.Mutex
+}
+
+type burstCounter struct {
+	start time.Time
+	count int
+}
+
+func (s *BurstSampler) Sample(event *Event) (bool, float64) {
+	key := s.Key(event)
+	now := s.now()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if now.Sub(s.pruned) >= s.Interval {
+		for k, c := range s.counters {
+			if now.Sub(c.start) >= s.Interval {
+				delete(s.counters, k)
+			}
+		}
+		s.pruned = now
+	}
+	counter, exists := s.counters[key]
+	if !exists || now.Sub(counter.start) >= s.Interval {
+		counter = &burstCounter{start: now}
+		s.counters[key] = counter
+	}
+	counter.count++
+	if counter.count <= s.First {
+		return true, 1
+	}
+	if s.Thereafter > 0 && (counter.count-s.First)%s.Thereafter == 0 {
+		return true, float64(s.Thereafter)
+	}
+	return false, 0
+}
+
+// Creates new TailSampler that groups events by the value of the `key` field, such as a request ID, and keeps one
+// in `rate` groups.
+func NewTailSampler(key string, rate float64) *TailSampler {
+	return &TailSampler{
+		Key:     key,
+		Rate:    rate,
+		Timeout: 10 * time.Second,
+		random:  rand.Float64,
+		traces:  make(map[string]*trace),
+	}
+}
+
+// TailSampler is a Middleware that holds on to events with the same value of the Key field, such as all events of
+// a request, and decides whether to keep them once the group is complete. A group is complete when an event
+// matching Complete arrives or Timeout after its first event. Groups with an event matching Keep, such as an
+// error, are always kept; other groups are kept with a probability of 1/Rate. Events without the Key field pass
+// through. Flushing or closing the Emitter decides all pending groups.
+type TailSampler struct {
+	// Key of the field grouping events.
+	Key string
+
+	// Number of groups each kept group represents. Rate of 1 or less keeps every group.
+	Rate float64
+
+	// Matches events whose group is always kept. Nil never matches.
+	Keep Matcher
+
+	// Matches the last event of a group. Nil leaves groups to complete on Timeout.
+	Complete Matcher
+
+	// Time after the first event of a group at which the group is considered complete. Default is 10 seconds.
+	Timeout time.Duration
+
+	// Returns a random number in [0, 1).
+	random func() float64
+
+	traces map[string]*trace
+
+	mutex sync.Mutex
+}
+
+// Events of a group waiting for a decision, along with the Listeners to release them to.
+type trace struct {
+	events []*Event
+	nexts  []Listener
+	keep   bool
+	timer  *time.Timer
+}
+
+func (s *TailSampler) Handle(event *Event, next Listener) {
+	value, exists := event.Get(s.Key)
+	if !exists {
+		next(event)
+		return
+	}
+	key := fmt.Sprint(value)
+
+	s.mutex.Lock()
+	t, exists := s.traces[key]
+	if !exists {
+		t = &trace{}
+		s.traces[key] = t
+		if s.Timeout > 0 {
+			t.timer = time.AfterFunc(s.Timeout, func() {
+				s.complete(key, t)
+			})
+		}
+	}
+	t.events = append(t.events, event)
+	t.nexts = append(t.nexts, next)
+	if s.Keep != nil && s.Keep(event) {
+		t.keep = true
+	}
+	s.mutex.Unlock()
+
+	if s.Complete != nil && s.Complete(event) {
+		s.complete(key, t)
+	}
+}
+
+// Decides every pending group.
+func (s *TailSampler) Flush(ctx context.Context) error {
+	s.mutex.Lock()
+	traces := s.traces
+	s.traces = make(map[string]*trace)
+	s.mutex.Unlock()
+	for _, t := range traces {
+		s.decide(t)
+	}
+	return nil
+}
+
+// Decides the group unless it was decided already.
+func (s *TailSampler) complete(key string, t *trace) {
+	s.mutex.Lock()
+	if s.traces[key] != t {
+		s.mutex.Unlock()
+		return
+	}
+	delete(s.traces, key)
+	s.mutex.Unlock()
+	s.decide(t)
+}
+
+// Releases the events of a kept group to their Listeners, or drops them.
+func (s *TailSampler) decide(t *trace) {
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+	rate := 1.0
+	if !t.keep && s.Rate > 1 {
+		if s.random() >= 1/s.Rate {
+			return
+		}
+		rate = s.Rate
+	}
+	for i, event := range t.events {
+		t.nexts[i](withSampleRate(event, rate))
+	}
+}
@@ -0,0 +1,145 @@
+package telemetry
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFixedRateSamplerRecordsSampleRate(t *testing.T) {
+	sampler := NewFixedRateSampler(4)
+	random := []float64{0.1, 0.5, 0.9, 0.2}
+	sampler.random = func() float64 {
+		r := random[0]
+		random = random[1:]
+		return r
+	}
+	var kept []*Event
+	listener := Chain([]Middleware{Sample(sampler)}, func(event *Event) {
+		kept = append(kept, event)
+	})
+	for i := 0; i < 4; i++ {
+		listener(New().WithField("i", i))
+	}
+	require.Len(t, kept, 2)
+	require.Equal(t, 0, field(kept[0], "i"))
+	require.Equal(t, 4.0, field(kept[0], SampleRateKey))
+	require.Equal(t, 3, field(kept[1], "i"))
+}
+
+func TestTokenBucketSamplerLimitsEachKey(t *testing.T) {
+	now := time.Unix(0, 0)
+	sampler := NewTokenBucketSampler(FieldKey("tenant"), 1, 2)
+	sampler.now = func() time.Time {
+		return now
+	}
+	sample := func(tenant string) (bool, float64) {
+		return sampler.Sample(New().WithField("tenant", tenant))
+	}
+	require.Equal(t, true, first(sample("a")))
+	require.Equal(t, true, first(sample("a")))
+	require.Equal(t, false, first(sample("a")))
+	require.Equal(t, false, first(sample("a")))
+	require.Equal(t, true, first(sample("b")))
+	now = now.Add(time.Second)
+	keep, rate := sample("a")
+	require.True(t, keep)
+	require.Equal(t, 3.0, rate)
+}
+
+func TestTokenBucketSamplerPrunesFullBucketsOncePerRefill(t *testing.T) {
+	now := time.Unix(0, 0)
+	sampler := NewTokenBucketSampler(FieldKey("tenant"), 1, 2)
+	sampler.now = func() time.Time {
+		return now
+	}
+	for i := 0; i < maxTokenBuckets+2; i++ {
+		sampler.Sample(New().WithField("tenant", i))
+	}
+	require.Equal(t, now, sampler.pruned)
+	require.Len(t, sampler.buckets, maxTokenBuckets+2)
+
+	now = now.Add(time.Second)
+	sampler.Sample(New().WithField("tenant", "refilling"))
+	require.Len(t, sampler.buckets, maxTokenBuckets+3)
+
+	now = now.Add(time.Second)
+	sampler.Sample(New().WithField("tenant", "latest"))
+	require.Len(t, sampler.buckets, 1)
+	require.Equal(t, now, sampler.pruned)
+}
+
+func first(keep bool, rate float64) bool {
+	return keep
+}
+
+func TestBurstSamplerKeepsFirstThenEveryNth(t *testing.T) {
+	now := time.Unix(0, 0)
+	sampler := NewBurstSampler(2, 3, time.Minute)
+	sampler.now = func() time.Time {
+		return now
+	}
+	var kept []interface{}
+	for i := 0; i < 9; i++ {
+		if keep, _ := sampler.Sample(New().WithFields(Fields{"message": "boom", "i": i})); keep {
+			kept = append(kept, i)
+		}
+	}
+	require.Equal(t, []interface{}{0, 1, 4, 7}, kept)
+	keep, _ := sampler.Sample(New().WithFields(Fields{"message": "other"}))
+	require.True(t, keep)
+	now = now.Add(time.Minute)
+	keep, rate := sampler.Sample(New().WithFields(Fields{"message": "boom"}))
+	require.True(t, keep)
+	require.Equal(t, 1.0, rate)
+}
+
+func TestTailSamplerKeepsWholeRequests(t *testing.T) {
+	sampler := NewTailSampler("request_id", 10)
+	sampler.random = func() float64 {
+		return 0.5
+	}
+	sampler.Keep = MatchField("level", "error")
+	sampler.Complete = MatchField("message", "done")
+	emitter := NewEmitter()
+	emitter.Use(sampler)
+	var received []map[string]interface{}
+	emitter.AddListener(func(event *Event) {
+		received = append(received, event.Marshal())
+	})
+	emitter.Emit(New().WithFields(Fields{"request_id": "1", "message": "start"}))
+	emitter.Emit(New().WithFields(Fields{"request_id": "2", "message": "start"}))
+	emitter.Emit(New().WithFields(Fields{"message": "no request"}))
+	emitter.Emit(New().WithFields(Fields{"request_id": "1", "level": "error", "message": "failed"}))
+	require.Len(t, received, 1)
+	emitter.Emit(New().WithFields(Fields{"request_id": "1", "message": "done"}))
+	require.Len(t, received, 4)
+	require.Equal(t, "failed", received[2]["message"])
+	emitter.Emit(New().WithFields(Fields{"request_id": "3", "message": "start"}))
+	sampler.random = func() float64 {
+		return 0.05
+	}
+	require.NoError(t, emitter.Flush(context.Background()))
+	require.Len(t, received, 6)
+	require.ElementsMatch(t, []interface{}{"2", "3"}, []interface{}{received[4]["request_id"], received[5]["request_id"]})
+	require.Equal(t, 10.0, received[4][SampleRateKey])
+	require.Equal(t, 10.0, received[5][SampleRateKey])
+}
+
+func TestSamplerOnSubscription(t *testing.T) {
+	emitter := NewEmitter()
+	var all, sampled int
+	emitter.AddListener(func(event *Event) {
+		all++
+	})
+	emitter.AddListener(func(event *Event) {
+		sampled++
+	}).Use(Sample(NewBurstSampler(1, 0, time.Minute)))
+	for i := 0; i < 3; i++ {
+		emitter.Emit(New().WithField("message", "hello"))
+	}
+	require.Equal(t, 3, all)
+	require.Equal(t, 1, sampled)
+}
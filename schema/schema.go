@@ -0,0 +1,219 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tristanls/telemetry"
+)
+
+// Types of values, named as in JSON Schema.
+const (
+	String  = "string"
+	Number  = "number"
+	Integer = "integer"
+	Boolean = "boolean"
+	Object  = "object"
+	Array   = "array"
+	Null    = "null"
+)
+
+// Schema describes the fields of an event, or the value of a field, using a subset of JSON Schema: the "type",
+// "properties", "required", "additionalProperties" (as a boolean), "items", and "enum" keywords. Other keywords are
+// ignored.
+type Schema struct {
+	// Type of the value, one of String, Number, Integer, Boolean, Object, Array, or Null. Empty allows any type.
+	Type string `json:"type,omitempty"`
+
+	// Schemas of the fields of an object, which are optional unless Required.
+	Properties map[string]*Schema `json:"properties,omitempty"`
+
+	// Names of the fields an object must have.
+	Required []string `json:"required,omitempty"`
+
+	// Reject fields of an object not in Properties, which catches misspelled field names.
+	NoAdditionalProperties bool `json:"-"`
+
+	// Schema of the elements of an array.
+	Items *Schema `json:"items,omitempty"`
+
+	// Values the value must be one of. Numbers are compared by value regardless of their type.
+	Enum []interface{} `json:"enum,omitempty"`
+}
+
+// Parses a schema from a JSON Schema document.
+func Parse(data []byte) (*Schema, error) {
+	schema := new(Schema)
+	if err := json.Unmarshal(data, schema); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	type document Schema // without UnmarshalJSON
+	var raw struct {
+		*document
+		AdditionalProperties *bool `json:"additionalProperties"`
+	}
+	raw.document = (*document)(s)
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	switch s.Type {
+	case "", String, Number, Integer, Boolean, Object, Array, Null:
+	default:
+		return fmt.Errorf("schema: unsupported type %q", s.Type)
+	}
+	s.NoAdditionalProperties = raw.AdditionalProperties != nil && !*raw.AdditionalProperties
+	return nil
+}
+
+// ValidationError describes a value not matching its schema.
+type ValidationError struct {
+	// Dotted path of the field, with the index of array elements in brackets, such as "usage.tags[1]".
+	Path string
+
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+// Errors lists the ways an event doesn't match its schema.
+type Errors []*ValidationError
+
+func (errs Errors) Error() string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	return "invalid event: " + strings.Join(messages, "; ")
+}
+
+// Returns the ways the value doesn't match the schema, if any, sorted by path.
+func (s *Schema) Validate(value interface{}) Errors {
+	var errs Errors
+	s.validate("", value, &errs)
+	sort.SliceStable(errs, func(i, j int) bool {
+		return errs[i].Path < errs[j].Path
+	})
+	return errs
+}
+
+func (s *Schema) validate(path string, value interface{}, errs *Errors) {
+	if s.Type != "" && !hasType(value, s.Type) {
+		*errs = append(*errs, &ValidationError{Path: path, Message: fmt.Sprintf("expected %s, got %T", s.Type, value)})
+		return
+	}
+	if s.Enum != nil && !inEnum(value, s.Enum) {
+		*errs = append(*errs, &ValidationError{Path: path, Message: fmt.Sprintf("%v is not one of %v", value, s.Enum)})
+	}
+	if fields, ok := asFields(value); ok {
+		s.validateFields(path, fields, errs)
+	}
+	if s.Items != nil {
+		if items := reflect.ValueOf(value); items.Kind() == reflect.Slice || items.Kind() == reflect.Array {
+			for i := 0; i < items.Len(); i++ {
+				s.Items.validate(fmt.Sprintf("%s[%d]", path, i), items.Index(i).Interface(), errs)
+			}
+		}
+	}
+}
+
+func (s *Schema) validateFields(path string, fields map[string]interface{}, errs *Errors) {
+	prefix := path
+	if prefix != "" {
+		prefix += "."
+	}
+	for _, key := range s.Required {
+		if _, exists := fields[key]; !exists {
+			*errs = append(*errs, &ValidationError{Path: prefix + key, Message: "is required"})
+		}
+	}
+	for key, value := range fields {
+		property, exists := s.Properties[key]
+		switch {
+		case exists:
+			property.validate(prefix+key, value, errs)
+		case s.NoAdditionalProperties:
+			*errs = append(*errs, &ValidationError{Path: prefix + key, Message: "is not allowed"})
+		}
+	}
+}
+
+// Returns whether the value has the type, as it would once written as JSON. Times are strings and errors are
+// objects, as JSONFormatter writes them.
+func hasType(value interface{}, t string) bool {
+	switch value.(type) {
+	case nil:
+		return t == Null
+	case string, time.Time:
+		return t == String
+	case bool:
+		return t == Boolean
+	case error:
+		return t == Object
+	}
+	if _, ok := asFields(value); ok {
+		return t == Object
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return t == Integer || t == Number
+	case reflect.Float32, reflect.Float64:
+		return t == Number || (t == Integer && v.Float() == math.Trunc(v.Float()))
+	case reflect.Slice, reflect.Array:
+		return t == Array
+	}
+	return false
+}
+
+func inEnum(value interface{}, enum []interface{}) bool {
+	for _, allowed := range enum {
+		if a, ok := toFloat(value); ok {
+			if b, ok := toFloat(allowed); ok && a == b {
+				return true
+			}
+			continue
+		}
+		if reflect.DeepEqual(value, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+func asFields(value interface{}) (map[string]interface{}, bool) {
+	switch value := value.(type) {
+	case telemetry.Fields:
+		return value, true
+	case map[string]interface{}:
+		return value, true
+	}
+	return nil, false
+}
@@ -0,0 +1,114 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tristanls/telemetry"
+)
+
+const usageSchema = `{
+	"type": "object",
+	"required": ["tenantId", "usage"],
+	"additionalProperties": false,
+	"properties": {
+		"type": {"enum": ["usage"]},
+		"tenantId": {"type": "string"},
+		"usage": {
+			"type": "object",
+			"properties": {
+				"storage": {
+					"type": "object",
+					"required": ["unit", "value"],
+					"properties": {
+						"unit": {"type": "string", "enum": ["Req", "B"]},
+						"value": {"type": "integer"}
+					}
+				}
+			}
+		},
+		"tags": {"type": "array", "items": {"type": "string"}}
+	}
+}`
+
+func TestValidateAgainstJSONSchema(t *testing.T) {
+	registry := NewRegistry()
+	require.NoError(t, registry.Load("usage", []byte(usageSchema)))
+
+	_telemetry := telemetry.New()
+	valid := _telemetry.WithFields(telemetry.Fields{
+		"type":     "usage",
+		"tenantId": "tristan1234",
+		"usage":    telemetry.Fields{"storage": map[string]interface{}{"unit": "Req", "value": 2.0}},
+		"tags":     []string{"a", "b"},
+	})
+	require.Nil(t, registry.Validate(valid))
+
+	invalid := _telemetry.WithFields(telemetry.Fields{
+		"type":     "usage",
+		"tenantID": "tristan1234",
+		"usage":    telemetry.Fields{"storage": telemetry.Fields{"unit": "KB", "value": 2.5}},
+		"tags":     []interface{}{"a", 1},
+	})
+	require.Equal(t, []string{
+		"tags[1]: expected string, got int",
+		"tenantID: is not allowed",
+		"tenantId: is required",
+		"usage.storage.unit: KB is not one of [Req B]",
+		"usage.storage.value: expected integer, got float64",
+	}, messages(registry.Validate(invalid)))
+
+	require.Nil(t, registry.Validate(_telemetry.WithField("type", "log")))
+
+	_, err := Parse([]byte(`{"type": "strnig"}`))
+	require.EqualError(t, err, `schema: unsupported type "strnig"`)
+}
+
+func TestValidatorModes(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("metric", &Schema{
+		Type:     Object,
+		Required: []string{"name", "value"},
+		Properties: map[string]*Schema{
+			"name":  {Type: String},
+			"value": {Type: Number},
+		},
+	})
+	emitter := telemetry.NewEmitter()
+	var events []*telemetry.Event
+	emitter.AddListener(func(event *telemetry.Event) {
+		events = append(events, event)
+	})
+	var reported []*telemetry.InternalError
+	emitter.ErrorHandler = func(err *telemetry.InternalError) {
+		reported = append(reported, err)
+	}
+	validator := NewValidator(registry, Lenient)
+	validator.ErrorHandler = emitter.Report
+	emitter.Use(validator)
+
+	_telemetry := telemetry.New()
+	emitter.Emit(_telemetry.WithFields(telemetry.Fields{"type": "metric", "name": "requests", "value": 1}))
+	emitter.Emit(_telemetry.WithFields(telemetry.Fields{"type": "metric", "name": "requests"}))
+	require.Len(t, events, 2)
+	_, exists := events[0].Get(ValidationErrorsKey)
+	require.False(t, exists)
+	validationErrors, _ := events[1].Get(ValidationErrorsKey)
+	require.Equal(t, []string{"value: is required"}, validationErrors)
+
+	validator.Mode = Strict
+	emitter.Emit(_telemetry.WithFields(telemetry.Fields{"type": "metric", "name": 1, "value": 1}))
+	require.Len(t, events, 2)
+	require.Len(t, reported, 1)
+	require.Equal(t, "middleware failed: invalid event: name: expected string, got int", reported[0].Error())
+	var errs Errors
+	require.ErrorAs(t, reported[0], &errs)
+}
+
+func messages(errs Errors) []string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	return messages
+}
@@ -0,0 +1,132 @@
+package schema
+
+import (
+	"sync"
+
+	"github.com/tristanls/telemetry"
+)
+
+// Key of the field listing, in Lenient mode, how an event doesn't match its schema.
+var ValidationErrorsKey = "validation_errors"
+
+// Creates new Registry of event schemas keyed by the "type" field of events.
+func NewRegistry() *Registry {
+	return &Registry{
+		TypeKey: "type",
+		schemas: make(map[string]*Schema),
+	}
+}
+
+// Registry holds the schema of each event type, such as "log", "metric", or "usage".
+type Registry struct {
+	// Key of the field holding the type of an event. Default is "type".
+	TypeKey string
+
+	schemas map[string]*Schema
+
+	mutex sync.RWMutex
+}
+
+// Declares the schema of events of the type, replacing any previous one.
+func (r *Registry) Register(eventType string, schema *Schema) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.schemas[eventType] = schema
+}
+
+// Declares the schema of events of the type from a JSON Schema document.
+func (r *Registry) Load(eventType string, data []byte) error {
+	schema, err := Parse(data)
+	if err != nil {
+		return err
+	}
+	r.Register(eventType, schema)
+	return nil
+}
+
+// Returns the schema of events of the type, if one was registered.
+func (r *Registry) Schema(eventType string) (*Schema, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	schema, exists := r.schemas[eventType]
+	return schema, exists
+}
+
+// Returns how the fields of the event, except the ignored ones, don't match the schema of its type. Events
+// without a type, or of a type without a schema, are valid.
+func (r *Registry) Validate(event *telemetry.Event, ignore ...string) Errors {
+	eventType, _ := event.GetString(r.TypeKey)
+	schema, exists := r.Schema(eventType)
+	if !exists {
+		return nil
+	}
+	fields := event.Marshal()
+	for _, key := range ignore {
+		delete(fields, key)
+	}
+	return schema.Validate(fields)
+}
+
+// Mode decides what a Validator does with invalid events.
+type Mode int
+
+const (
+	// Pass invalid events through with a ValidationErrorsKey field listing how they are invalid.
+	Lenient Mode = iota
+	// Drop invalid events and report them as internal errors.
+	Strict
+)
+
+// Creates new Validator checking events against the schemas in the registry. In Strict mode, set its ErrorHandler,
+// typically to the Emitter's Report method, to receive rejected events.
+func NewValidator(registry *Registry, mode Mode) *Validator {
+	return &Validator{
+		Registry: registry,
+		Mode:     mode,
+		Ignore: []string{
+			telemetry.ProvenanceKey, telemetry.TimestampKey, telemetry.IDKey, telemetry.SequenceKey,
+			telemetry.CallerKey, telemetry.StacktraceKey, telemetry.SampleRateKey, telemetry.RepeatsKey,
+			ValidationErrorsKey,
+		},
+	}
+}
+
+// Validator is a Middleware checking events against the schema of their type.
+type Validator struct {
+	Registry *Registry
+
+	Mode Mode
+
+	// Called in Strict mode with an internal error whose Err is the Errors of a rejected event. Default writes the
+	// error to os.Stderr.
+	ErrorHandler func(*telemetry.InternalError)
+
+	// Keys of fields not validated because the pipeline rather than the emitting code adds them. Default is the
+	// keys of provenance, timestamp, ID, sequence number, caller, stack trace, sample rate, repeats, and validation
+	// errors.
+	Ignore []string
+}
+
+func (v *Validator) Handle(event *telemetry.Event, next telemetry.Listener) {
+	errs := v.Registry.Validate(event, v.Ignore...)
+	if errs == nil {
+		next(event)
+		return
+	}
+	if v.Mode == Lenient {
+		messages := make([]string, len(errs))
+		for i, err := range errs {
+			messages[i] = err.Error()
+		}
+		next(event.WithField(ValidationErrorsKey, messages))
+		return
+	}
+	internalError := &telemetry.InternalError{Event: event, Err: errs}
+	if v.ErrorHandler != nil {
+		v.ErrorHandler(internalError)
+		return
+	}
+	telemetry.WriteInternalError(internalError)
+}
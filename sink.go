@@ -0,0 +1,56 @@
+package telemetry
+
+import (
+	"context"
+	"strings"
+)
+
+// Sink is a Listener that holds on to resources, such as buffers or connections, which need to be flushed before
+// the process exits and released when no longer needed. Writer and Emitter are Sinks.
+type Sink interface {
+	// Listen handles an emitted event.
+	Listen(event *Event)
+
+	// Flush writes out any buffered events, giving up when the context is done.
+	Flush(ctx context.Context) error
+
+	// Close flushes buffered events and releases resources, giving up when the context is done.
+	Close(ctx context.Context) error
+}
+
+// Flusher is implemented by Middleware that holds on to events, such as TailSampler, so that flushing or closing
+// an Emitter releases them.
+type Flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// SinkError describes a failure to flush or close a Sink.
+type SinkError struct {
+	// Subscription of the Sink that failed. Nil if it was the Emitter's own queue or middleware that failed to
+	// drain.
+	Subscription *Subscription
+
+	Err error
+}
+
+func (e SinkError) Error() string {
+	if e.Subscription == nil {
+		return "emitter: " + e.Err.Error()
+	}
+	return e.Subscription.String() + ": " + e.Err.Error()
+}
+
+func (e SinkError) Unwrap() error {
+	return e.Err
+}
+
+// DrainError lists the Sinks that failed to flush or close.
+type DrainError []SinkError
+
+func (errs DrainError) Error() string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	return "failed to drain: " + strings.Join(messages, "; ")
+}
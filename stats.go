@@ -0,0 +1,111 @@
+package telemetry
+
+import (
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+// EmitterStats is a snapshot of delivery statistics of an Emitter.
+type EmitterStats struct {
+	// Number of events emitted.
+	Emitted uint64
+
+	// Number of events dropped because the queue was full or the Emitter was closed.
+	Dropped uint64
+
+	// Number of queued events not yet delivered.
+	Queued int
+
+	// Statistics of each Subscription.
+	Subscriptions []SubscriptionStats
+}
+
+// SubscriptionStats is a snapshot of delivery statistics of a Subscription.
+type SubscriptionStats struct {
+	ID   uint64
+	Name string
+
+	// Number of events that matched the Subscription's matchers.
+	Received uint64
+
+	// Number of events handed to the Listener.
+	Delivered uint64
+
+	// Number of received events not handed to the Listener, because the Subscription's middleware dropped them or
+	// because the Subscription was removed. Events held by middleware, such as a TailSampler, count as dropped
+	// until they are released.
+	Dropped uint64
+
+	// Number of times the Listener, or the Subscription's matchers or middleware, panicked.
+	Panicked uint64
+
+	// Percentiles of the time it took the Listener to handle an event. Values are approximate, within 25% of the
+	// actual duration.
+	P50 time.Duration
+	P90 time.Duration
+	P99 time.Duration
+}
+
+// Number of buckets in a histogram: four buckets for each power of two of nanoseconds.
+const histogramBuckets = 64 * 4
+
+// Histogram of durations with logarithmic buckets, safe for concurrent use.
+type histogram struct {
+	buckets [histogramBuckets]uint64
+}
+
+func (h *histogram) record(duration time.Duration) {
+	if duration < 0 {
+		duration = 0
+	}
+	atomic.AddUint64(&h.buckets[bucketIndex(uint64(duration))], 1)
+}
+
+// Returns the upper bounds of the buckets containing each of the requested percentiles, from 0 to 1.
+func (h *histogram) percentiles(percentiles ...float64) []time.Duration {
+	var counts [histogramBuckets]uint64
+	var total uint64
+	for i := range h.buckets {
+		counts[i] = atomic.LoadUint64(&h.buckets[i])
+		total += counts[i]
+	}
+	result := make([]time.Duration, len(percentiles))
+	if total == 0 {
+		return result
+	}
+	for p, percentile := range percentiles {
+		rank := uint64(percentile * float64(total))
+		if rank >= total {
+			rank = total - 1
+		}
+		var cumulative uint64
+		for i, count := range counts {
+			cumulative += count
+			if cumulative > rank {
+				result[p] = time.Duration(bucketUpperBound(i))
+				break
+			}
+		}
+	}
+	return result
+}
+
+// Values below 4 have a bucket each. Larger values are split into four buckets per power of two, using the two
+// bits following the most significant one.
+func bucketIndex(value uint64) int {
+	if value < 4 {
+		return int(value)
+	}
+	exponent := bits.Len64(value) - 1
+	mantissa := (value >> uint(exponent-2)) & 3
+	return exponent*4 + int(mantissa)
+}
+
+func bucketUpperBound(index int) uint64 {
+	if index < 4 {
+		return uint64(index)
+	}
+	exponent, mantissa := uint(index/4), uint64(index%4)
+	return (5+mantissa)<<(exponent-2) - 1
+}
@@ -0,0 +1,162 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Subscription is a handle to a Listener added to an Emitter. It can be used to stop the Listener from
+// receiving further events.
+type Subscription struct {
+	// Identifier of the Subscription, unique within its Emitter.
+	id uint64
+
+	// Name of the Sink type or Listener function, used to identify the Subscription in errors.
+	name string
+
+	// Emitter the Subscription belongs to.
+	emitter *Emitter
+
+	// Handles events delivered to the Subscription.
+	handler ContextListener
+
+	// Sink the listener belongs to, if it was added with AddSink.
+	sink Sink
+
+	// Matcher events must satisfy to be delivered. Nil matches every event.
+	matcher Matcher
+
+	// Middleware events pass through before being handled, stored as []Middleware. The slice is replaced, never
+	// modified in place.
+	middleware atomic.Value
+	mutex      sync.Mutex
+
+	// Set to 1 once unsubscribed.
+	closed int32
+
+	// Delivery statistics.
+	received  uint64
+	delivered uint64
+	panicked  uint64
+	latency   histogram
+}
+
+// Returns identifier of the Subscription, unique within its Emitter.
+func (s *Subscription) ID() uint64 {
+	return s.id
+}
+
+// Removes the Listener from the Emitter. It is safe to call while another goroutine is emitting, but does not wait
+// for calls to the Listener in progress: a call that started, or was about to start, before Unsubscribe returned
+// may still complete afterwards. The Listener eventually stops receiving events. Calling Unsubscribe more than once
+// has no effect.
+func (s *Subscription) Unsubscribe() {
+	if !atomic.CompareAndSwapInt32(&s.closed, 0, 1) {
+		return
+	}
+	s.emitter.remove(s)
+}
+
+// Adds middleware that events delivered to this Subscription pass through, in order, before being handled. Returns
+// the Subscription so that it can be used as in `emitter.AddListener(listener).Use(telemetry.Sample(sampler))`.
+func (s *Subscription) Use(middleware ...Middleware) *Subscription {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	existing, _ := s.middleware.Load().([]Middleware)
+	combined := make([]Middleware, 0, len(existing)+len(middleware))
+	combined = append(combined, existing...)
+	s.middleware.Store(append(combined, middleware...))
+	return s
+}
+
+// Returns a snapshot of delivery statistics of the Subscription.
+func (s *Subscription) Stats() SubscriptionStats {
+	received := atomic.LoadUint64(&s.received)
+	delivered := atomic.LoadUint64(&s.delivered)
+	var dropped uint64
+	if received > delivered {
+		dropped = received - delivered
+	}
+	percentiles := s.latency.percentiles(0.5, 0.9, 0.99)
+	return SubscriptionStats{
+		ID:        s.id,
+		Name:      s.name,
+		Received:  received,
+		Delivered: delivered,
+		Dropped:   dropped,
+		Panicked:  atomic.LoadUint64(&s.panicked),
+		P50:       percentiles[0],
+		P90:       percentiles[1],
+		P99:       percentiles[2],
+	}
+}
+
+// Returns name of the Sink type or Listener function of the Subscription.
+func (s *Subscription) Name() string {
+	return s.name
+}
+
+// Returns a description of the Subscription for use in error messages.
+func (s *Subscription) String() string {
+	if s.name == "" {
+		return fmt.Sprintf("subscription %d", s.id)
+	}
+	return fmt.Sprintf("subscription %d (%s)", s.id, s.name)
+}
+
+// Close is the same as Unsubscribe. It implements the io.Closer interface.
+func (s *Subscription) Close() error {
+	s.Unsubscribe()
+	return nil
+}
+
+// Calls the Listener with the event unless the Subscription has been unsubscribed or the event does not match.
+func (s *Subscription) handle(ctx context.Context, event *Event) {
+	if atomic.LoadInt32(&s.closed) == 1 {
+		return
+	}
+	if s.matcher != nil && !s.matcher(event) {
+		return
+	}
+	atomic.AddUint64(&s.received, 1)
+	middleware, _ := s.middleware.Load().([]Middleware)
+	if middleware == nil {
+		s.call(ctx, event)
+		return
+	}
+	// Middleware may hold on to the event and release it later on another goroutine.
+	Chain(middleware, func(event *Event) {
+		if atomic.LoadInt32(&s.closed) == 1 {
+			return
+		}
+		s.emitter.invoke(ctx, s, event)
+	})(event)
+}
+
+// Calls the handler, recording how long it took.
+func (s *Subscription) call(ctx context.Context, event *Event) {
+	atomic.AddUint64(&s.delivered, 1)
+	start := time.Now()
+	s.handler(ctx, event)
+	s.latency.record(time.Since(start))
+}
+
+// Flushes middleware that holds on to events.
+func (s *Subscription) flushMiddleware(ctx context.Context) error {
+	middleware, _ := s.middleware.Load().([]Middleware)
+	return flushMiddleware(ctx, middleware)
+}
+
+// Returns name of the function, used to identify a Listener.
+func functionName(function interface{}) string {
+	if fn := runtime.FuncForPC(reflect.ValueOf(function).Pointer()); fn != nil {
+		return fn.Name()
+	}
+	return ""
+}
@@ -1,55 +1,72 @@
 package telemetry
 
 import (
-	"sync"
+	"time"
 )
 
 type Fields map[string]interface{}
 
-// Creates new Telemetry instance. You can set what layout is used for timestamp formatting by
-// changing `TimestampLayout` property.
+// Creates new Telemetry instance. You can set the clock telling the time of events by changing the `Clock`
+// property.
 func New() *Telemetry {
 	return &Telemetry{
-		TimestampLayout: "2006-01-02T15:04:05.000Z",
+		TimestampLayout: DefaultTimestampLayout,
+		Encoders:        NewEncoders(),
 	}
 }
 
 type Telemetry struct {
-	// Timestamp layout to be used with time.Format(layout) when creating timestamp for an event.
-	// Default is "2006-01-02T15:04:05.000Z". Timestamps are always in UTC timezone (Z), so make
-	// sure your layout includes timezone information or assumes UTC.
+	// Tells the time stored under TimestampKey when an event is emitted. Default is the system clock.
+	Clock Clock
+
+	// Timestamp layout used to parse string timestamps, such as those of events emitted before timestamps were
+	// stored as time.Time, with Event.GetTime. A Writer also formats times of the Telemetry's events it receives
+	// with Listen or ListenBatch with it unless its JSONFormatter has a TimestampLayout of its own, but not those of
+	// events already marshaled and passed to Write or WriteBatch.
+	//
+	// Deprecated: Timestamps are stored as time.Time and formatted by the Formatter, so set the TimestampLayout of
+	// JSONFormatter instead.
 	TimestampLayout string
 
-	// Reusable empty event pool.
-	eventPool sync.Pool
-}
+	// Encode field values when events are marshaled, so that every Formatter writes them the same way. Default is
+	// NewEncoders(). Values implementing FieldMarshaler are encoded even if Encoders is nil.
+	Encoders *Encoders
 
-func (t *Telemetry) newEvent() *Event {
-	event, ok := t.eventPool.Get().(*Event)
-	if ok {
-		return event
-	}
-	return NewEvent(t)
+	// Add the caller of the function creating each event under CallerKey, as with Event.WithCaller.
+	CaptureCaller bool
+
+	// Add the stack trace of the function creating each event under StacktraceKey, as with Event.WithStacktrace.
+	CaptureStacktrace bool
+
+	// Add a unique ID to each emitted event under IDKey. IDs are ULIDs, which sort in the order the events were
+	// emitted in, to the millisecond across processes and exactly within this process.
+	AssignID bool
+
+	// Add the sequence number of each emitted event under SequenceKey. Sequence numbers increase monotonically
+	// across all events emitted in this process.
+	AssignSequence bool
 }
 
-func (t *Telemetry) releaseEvent(event *Event) {
-	t.eventPool.Put(event)
+// Returns the time of the Telemetry's clock.
+func (t *Telemetry) now() time.Time {
+	if t.Clock == nil {
+		return time.Now()
+	}
+	return t.Clock.Now()
 }
 
 func (t *Telemetry) WithField(key string, value interface{}) *Event {
-	event := t.newEvent()
-	defer t.releaseEvent(event)
-	return event.WithField(key, value)
+	return NewEventSkip(t, 1).WithField(key, value)
 }
 
 func (t *Telemetry) WithFields(fields Fields) *Event {
-	event := t.newEvent()
-	defer t.releaseEvent(event)
-	return event.WithFields(fields)
+	return NewEventSkip(t, 1).WithFields(fields)
+}
+
+func (t *Telemetry) WithError(err error) *Event {
+	return NewEventSkip(t, 1).WithError(err)
 }
 
 func (t *Telemetry) WithProvenance(fields Fields) *Event {
-	event := t.newEvent()
-	defer t.releaseEvent(event)
-	return event.WithProvenance(fields)
+	return NewEventSkip(t, 1).WithProvenance(fields)
 }
@@ -0,0 +1,77 @@
+package telemetry
+
+import (
+	"sync"
+)
+
+// Valuer is implemented by field values that are expensive to compute, such as a serialized request body. The
+// value is resolved lazily, only once the event is read by Get, Marshal, or a Formatter, so that events discarded
+// before then don't pay for it.
+type Valuer interface {
+	Value() interface{}
+}
+
+// ValuerFunc adapts an ordinary function to the Valuer interface.
+type ValuerFunc func() interface{}
+
+func (f ValuerFunc) Value() interface{} {
+	return f()
+}
+
+// Maximum number of Valuers resolving to another Valuer that are resolved, which guards against a Valuer resolving
+// to itself.
+const maxValuerDepth = 100
+
+// Returns the value, resolving Valuers.
+func resolve(value interface{}) interface{} {
+	for i := 0; i < maxValuerDepth; i++ {
+		valuer, ok := value.(Valuer)
+		if !ok {
+			return value
+		}
+		value = valuer.Value()
+	}
+	return value
+}
+
+// Valuer resolving another Valuer at most once, so that listeners reading the same event share its value.
+type cachedValuer struct {
+	valuer Valuer
+	once   sync.Once
+	value  interface{}
+}
+
+func (c *cachedValuer) Value() interface{} {
+	c.once.Do(func() {
+		c.value = resolve(c.valuer)
+	})
+	return c.value
+}
+
+// Replaces Valuers among the fields with cached ones, in place.
+func cacheValuers(fields Fields) {
+	for k, v := range fields {
+		fields[k] = cacheValuer(v)
+	}
+}
+
+// Returns the value with Valuers, including those in nested fields and slices, replaced with cached ones. Nested
+// fields and slices are copied only if they contain a Valuer that is not cached yet.
+func cacheValuer(value interface{}) interface{} {
+	w := walker{visit: visitValuer}
+	cached, _ := w.walk("", "", value, 0)
+	return cached
+}
+
+// Replaces a Valuer with a cached one unless it is cached already.
+func visitValuer(path string, key string, value interface{}, depth int) (interface{}, bool, bool) {
+	switch value := value.(type) {
+	case *cachedValuer:
+		return value, false, false
+	case Valuer:
+		return &cachedValuer{valuer: value}, true, false
+	case []string:
+		return value, false, false
+	}
+	return value, false, true
+}
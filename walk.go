@@ -0,0 +1,141 @@
+package telemetry
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Visits a value found by a walker: the value of the field with the key, or an element of a slice if key is empty,
+// at the dotted path and depth. Returns the replacement of the value, whether it changed, and whether to descend
+// into the replacement. A replacement that did not change, such as an error described by formatError, is used only
+// if something in it changes.
+type visitor func(path string, key string, value interface{}, depth int) (interface{}, bool, bool)
+
+// Walker replaces values in field values, descending into nested Fields, map[string]interface{}, []interface{},
+// []Fields, []map[string]interface{}, and []string values, which are copied only if something in them is replaced.
+// Redaction, encoding, limits, and caching of Valuers share it, so that they find the same values. Fields of the
+// event are at depth 1, and fields nested in them one deeper; slice elements are at the depth of the slice.
+type walker struct {
+	visit visitor
+
+	// Resolve Valuers before visiting values, and marshal FieldMarshalers that are not replaced and visit the
+	// result, as Formatters write them.
+	resolve bool
+
+	// Return resolved Valuers and marshaled FieldMarshalers even if nothing in them changed.
+	written bool
+
+	// Pass dotted paths, such as "request.headers[0]", to visit. Otherwise, paths are empty.
+	paths bool
+}
+
+// Returns the value walked and whether it changed.
+func (w *walker) walk(path string, key string, value interface{}, depth int) (interface{}, bool) {
+	original := value
+	var written, marshaled bool
+	for {
+		if w.resolve {
+			if _, ok := value.(Valuer); ok {
+				value, written = resolve(value), true
+			}
+		}
+		replacement, changed, descend := w.visit(path, key, value, depth)
+		if !descend {
+			if changed || written && w.written {
+				return replacement, true
+			}
+			return original, false
+		}
+		if marshaler, ok := replacement.(FieldMarshaler); ok && w.resolve && !marshaled {
+			value, written, marshaled = marshalField(marshaler), true, true
+			continue
+		}
+		nested, nestedChanged := w.descend(path, replacement, depth)
+		if changed || nestedChanged || written && w.written {
+			return nested, true
+		}
+		return original, false
+	}
+}
+
+// Returns the nested fields or slice walked and whether they changed. Other values are returned as they are.
+func (w *walker) descend(path string, value interface{}, depth int) (interface{}, bool) {
+	switch value := value.(type) {
+	case Fields:
+		return w.walkFields(path, value, depth)
+	case map[string]interface{}:
+		fields, changed := w.walkFields(path, value, depth)
+		return map[string]interface{}(fields), changed
+	case []interface{}, []Fields, []map[string]interface{}, []string:
+		return w.walkSlice(path, reflect.ValueOf(value), depth)
+	}
+	return value, false
+}
+
+// Returns the fields at the depth walked and whether they changed. The fields are copied only if they change.
+func (w *walker) walkFields(path string, fields map[string]interface{}, depth int) (Fields, bool) {
+	var walked Fields
+	for k, v := range fields {
+		if v, changed := w.walk(w.childPath(path, k), k, v, depth+1); changed {
+			if walked == nil {
+				walked = copyFields(fields)
+			}
+			walked[k] = v
+		}
+	}
+	if walked == nil {
+		return fields, false
+	}
+	return walked, true
+}
+
+// Returns the slice walked and whether it changed. The slice is copied only if it changes, into an []interface{}
+// if a replaced element doesn't fit the slice's type.
+func (w *walker) walkSlice(path string, slice reflect.Value, depth int) (interface{}, bool) {
+	var walked reflect.Value
+	for i := 0; i < slice.Len(); i++ {
+		v, changed := w.walk(w.elementPath(path, i), "", slice.Index(i).Interface(), depth)
+		if !changed {
+			continue
+		}
+		element := reflect.Zero(slice.Type().Elem())
+		if v != nil {
+			element = reflect.ValueOf(v)
+		}
+		if !walked.IsValid() {
+			walked = reflect.MakeSlice(slice.Type(), slice.Len(), slice.Len())
+			reflect.Copy(walked, slice)
+		}
+		if !element.Type().AssignableTo(walked.Type().Elem()) {
+			elements := make([]interface{}, walked.Len())
+			for j := range elements {
+				elements[j] = walked.Index(j).Interface()
+			}
+			walked = reflect.ValueOf(elements)
+		}
+		walked.Index(i).Set(element)
+	}
+	if !walked.IsValid() {
+		return slice.Interface(), false
+	}
+	return walked.Interface(), true
+}
+
+// Returns the dotted path of the key within the path.
+func (w *walker) childPath(path string, key string) string {
+	if !w.paths {
+		return ""
+	}
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// Returns the path of the element of the slice at the path.
+func (w *walker) elementPath(path string, i int) string {
+	if !w.paths {
+		return ""
+	}
+	return fmt.Sprintf("%s[%d]", path, i)
+}
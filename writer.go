@@ -2,6 +2,7 @@ package telemetry
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -37,17 +38,37 @@ type Writer struct {
 
 	// Use for locking when writing to Out.
 	mutex sync.Mutex
+
+	// Set once the Writer is closed.
+	closed bool
+}
+
+// Listen writes the marshaled event. It implements the Sink interface so that a Writer can be added to an Emitter.
+// Times are formatted with the TimestampLayout of the event's Telemetry unless the Formatter has a layout of its own.
+func (writer *Writer) Listen(event *Event) {
+	writer.write(event.Marshal(), event.timestampLayout())
 }
 
-func (writer Writer) Write(event map[string]interface{}) {
+// Write formats and writes the event. Times are formatted with the Formatter's layout, or DefaultTimestampLayout,
+// since a marshaled event has no Telemetry whose TimestampLayout could be used.
+func (writer *Writer) Write(event map[string]interface{}) {
+	writer.write(event, "")
+}
+
+// Writes the event, passing the layout of times to the Formatter if it accepts one.
+func (writer *Writer) write(event map[string]interface{}, layout string) {
 	var buffer *bytes.Buffer
 	buffer = bufferPool.Get().(*bytes.Buffer)
 	buffer.Reset()
 	defer bufferPool.Put(buffer)
-	serialized, err := writer.Formatter.Format(event)
+	serialized, err := formatWithLayout(writer.Formatter, event, layout)
 	if err != nil {
 		message := fmt.Sprintf("{\"type\":\"log\",\"level\":\"error\",\"message\":\"%v\"}\n", err)
 		writer.mutex.Lock()
+		if writer.closed {
+			writer.mutex.Unlock()
+			return
+		}
 		_, err = writer.Out.Write([]byte(message))
 		if err != nil {
 			fmt.Fprintf(os.Stderr, message)
@@ -56,9 +77,129 @@ func (writer Writer) Write(event map[string]interface{}) {
 		return
 	}
 	writer.mutex.Lock()
+	if writer.closed {
+		writer.mutex.Unlock()
+		return
+	}
 	_, err = writer.Out.Write(serialized)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "{\"type\":\"log\",\"level\":\"error\",\"message\":\"%v\"}\n", err)
 	}
 	writer.mutex.Unlock()
 }
+
+// ListenBatch writes the marshaled events. It implements the BatchSink interface so that a Writer can be fed by a
+// Batcher. Times are formatted as by Listen.
+func (writer *Writer) ListenBatch(events []*Event) {
+	marshaled := make([]map[string]interface{}, len(events))
+	layouts := make([]string, len(events))
+	for i, event := range events {
+		marshaled[i] = event.Marshal()
+		layouts[i] = event.timestampLayout()
+	}
+	writer.writeBatch(marshaled, layouts)
+}
+
+// Formats the events into a single buffer and writes it to Out with one call. Events that fail to format are
+// replaced with an error log event. Times are formatted as by Write.
+func (writer *Writer) WriteBatch(events []map[string]interface{}) {
+	writer.writeBatch(events, nil)
+}
+
+// Writes the events like WriteBatch, passing the layout of times of each event, if any, to the Formatter if it
+// accepts one.
+func (writer *Writer) writeBatch(events []map[string]interface{}, layouts []string) {
+	var buffer *bytes.Buffer
+	buffer = bufferPool.Get().(*bytes.Buffer)
+	buffer.Reset()
+	defer bufferPool.Put(buffer)
+	for i, event := range events {
+		var layout string
+		if layouts != nil {
+			layout = layouts[i]
+		}
+		serialized, err := formatWithLayout(writer.Formatter, event, layout)
+		if err != nil {
+			fmt.Fprintf(buffer, "{\"type\":\"log\",\"level\":\"error\",\"message\":\"%v\"}\n", err)
+			continue
+		}
+		buffer.Write(serialized)
+	}
+	writer.mutex.Lock()
+	if writer.closed {
+		writer.mutex.Unlock()
+		return
+	}
+	_, err := writer.Out.Write(buffer.Bytes())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "{\"type\":\"log\",\"level\":\"error\",\"message\":\"%v\"}\n", err)
+	}
+	writer.mutex.Unlock()
+}
+
+// Flushes Out if it buffers writes, that is, if it has a `Flush() error` method (like bufio.Writer) or is a file
+// other than standard output or standard error. Gives up when the context is done.
+func (writer *Writer) Flush(ctx context.Context) error {
+	return run(ctx, func() error {
+		writer.mutex.Lock()
+		defer writer.mutex.Unlock()
+
+		if writer.closed {
+			return nil
+		}
+		return writer.flush()
+	})
+}
+
+// Flushes Out and then closes it if it is an io.Closer other than standard output or standard error. Events
+// written after Close are discarded. Gives up when the context is done.
+func (writer *Writer) Close(ctx context.Context) error {
+	return run(ctx, func() error {
+		writer.mutex.Lock()
+		defer writer.mutex.Unlock()
+
+		if writer.closed {
+			return nil
+		}
+		writer.closed = true
+		err := writer.flush()
+		if closer, ok := writer.Out.(io.Closer); ok && !isStandardStream(writer.Out) {
+			if closeErr := closer.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		return err
+	})
+}
+
+// Flushes Out. Must be called with mutex held.
+func (writer *Writer) flush() error {
+	switch out := writer.Out.(type) {
+	case interface{ Flush() error }:
+		return out.Flush()
+	case *os.File:
+		if isStandardStream(out) {
+			return nil // syncing a terminal or pipe fails, and there is nothing to sync
+		}
+		return out.Sync()
+	}
+	return nil
+}
+
+// Runs the operation, returning early with the context error if the context is done first.
+func run(ctx context.Context, operation func() error) error {
+	result := make(chan error, 1)
+	go func() {
+		result <- operation()
+	}()
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func isStandardStream(out io.Writer) bool {
+	return out == os.Stdout || out == os.Stderr
+}